@@ -0,0 +1,302 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/lunatictiol/go-based-social-media/internal/search"
+	"github.com/lunatictiol/go-based-social-media/internal/store"
+)
+
+type userRolePayload struct {
+	Role string `json:"role" validate:"required,max=50"`
+}
+
+type userActivePayload struct {
+	Active *bool `json:"active" validate:"required"`
+}
+
+// getManagedUser returns the account of the userID path parameter when the
+// signed in admin's role is at or above its role. Otherwise it writes the
+// error response and returns nil.
+func (a *application) getManagedUser(w http.ResponseWriter, r *http.Request) *store.User {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		a.BadRequestResponse(w, r, err)
+		return nil
+	}
+
+	ctx := r.Context()
+
+	target, err := a.store.Users.GetAccount(ctx, userID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			a.NotfoundResponse(w, r, err)
+		default:
+			a.WriteInternalServerError(w, r, err)
+		}
+		return nil
+	}
+
+	allowed, err := a.checkRolePrecedence(ctx, getUserfromCtx(r), target.Role.Name)
+	if err != nil {
+		a.WriteInternalServerError(w, r, err)
+		return nil
+	}
+	if !allowed {
+		a.forbiddenResponse(w, r)
+		return nil
+	}
+
+	return target
+}
+
+// ListUsers godoc
+//
+//	@Summary		Lists accounts
+//	@Description	Lists accounts, active or not, newest first. q matches the start of the username, display name or email. Moderators only.
+//	@Tags			moderation
+//	@Produce		json
+//	@Param			q		query		string	false	"Search"
+//	@Param			role	query		string	false	"Role name, like moderator"
+//	@Param			active	query		bool	false	"Active accounts only, or inactive only"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Success		200		{array}		store.User
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users [get]
+func (a *application) listUsersHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	uq := store.UserListQuery{Search: qs.Get("q"), Role: qs.Get("role"), Limit: 20}
+	if active := qs.Get("active"); active != "" {
+		b, err := strconv.ParseBool(active)
+		if err != nil {
+			a.BadRequestResponse(w, r, err)
+			return
+		}
+		uq.Active = &b
+	}
+	if limit := qs.Get("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			a.BadRequestResponse(w, r, err)
+			return
+		}
+		uq.Limit = l
+	}
+	if offset := qs.Get("offset"); offset != "" {
+		o, err := strconv.Atoi(offset)
+		if err != nil {
+			a.BadRequestResponse(w, r, err)
+			return
+		}
+		uq.Offset = o
+	}
+	if err := Validator.Struct(uq); err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+
+	users, err := a.store.Users.List(r.Context(), uq)
+	if err != nil {
+		a.WriteInternalServerError(w, r, err)
+		return
+	}
+
+	if err := a.jsonResponse(w, http.StatusOK, users); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
+
+// SetUserRole godoc
+//
+//	@Summary		Changes a user's role
+//	@Description	Gives a user another role. Admins can only manage users whose role is at or below their own and only grant roles up to their own. The last active admin can't be demoted. Admins only.
+//	@Tags			moderation
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path		int				true	"User ID"
+//	@Param			payload	body		userRolePayload	true	"Role"
+//	@Success		200		{object}	store.User
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error	"Last admin"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID}/role [put]
+func (a *application) setUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	var payload userRolePayload
+	if err := ReadJSON(w, r, &payload); err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+	if err := Validator.Struct(payload); err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+
+	target := a.getManagedUser(w, r)
+	if target == nil {
+		return
+	}
+
+	ctx := r.Context()
+
+	role, err := a.store.Roles.GetByName(ctx, payload.Role)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			a.BadRequestResponse(w, r, err)
+		default:
+			a.WriteInternalServerError(w, r, err)
+		}
+		return
+	}
+
+	allowed, err := a.checkRolePrecedence(ctx, getUserfromCtx(r), role.Name)
+	if err != nil {
+		a.WriteInternalServerError(w, r, err)
+		return
+	}
+	if !allowed {
+		a.forbiddenResponse(w, r)
+		return
+	}
+
+	if err := a.store.Users.SetRole(ctx, target.Id, role); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			a.NotfoundResponse(w, r, err)
+		case store.ErrLastAdmin:
+			a.conflictResponse(w, r, err)
+		default:
+			a.WriteInternalServerError(w, r, err)
+		}
+		return
+	}
+	a.forgetUser(ctx, target.Id)
+
+	a.recordAudit(r, store.AuditEvent{
+		Action:     store.AuditRoleChanged,
+		TargetType: store.ReportTargetUser,
+		TargetId:   &target.Id,
+		Metadata:   map[string]any{"from": target.Role.Name, "to": role.Name},
+	})
+
+	target.Role = *role
+	target.Role_Id = role.ID
+
+	if err := a.jsonResponse(w, http.StatusOK, target); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
+
+// SetUserActive godoc
+//
+//	@Summary		Activates or deactivates an account
+//	@Description	Deactivated users can't sign in and their tokens are revoked. Activating an account also skips its email confirmation. Admins can only manage users whose role is at or below their own. The last active admin can't be deactivated. Admins only.
+//	@Tags			moderation
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path		int					true	"User ID"
+//	@Param			payload	body		userActivePayload	true	"Status"
+//	@Success		200		{object}	store.User
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error	"Last admin"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID}/active [put]
+func (a *application) setUserActiveHandler(w http.ResponseWriter, r *http.Request) {
+	var payload userActivePayload
+	if err := ReadJSON(w, r, &payload); err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+	if err := Validator.Struct(payload); err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+
+	target := a.getManagedUser(w, r)
+	if target == nil {
+		return
+	}
+
+	ctx := r.Context()
+	active := *payload.Active
+
+	if err := a.store.Users.SetActive(ctx, target.Id, active); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			a.NotfoundResponse(w, r, err)
+		case store.ErrLastAdmin:
+			a.conflictResponse(w, r, err)
+		default:
+			a.WriteInternalServerError(w, r, err)
+		}
+		return
+	}
+	a.forgetUser(ctx, target.Id)
+
+	action, event := store.AuditActivated, search.UserChanged
+	if !active {
+		action, event = store.AuditDeactivated, search.UserDeleted
+	}
+	a.searchEvents.Publish(search.Event{Type: event, ID: target.Id})
+	a.recordAudit(r, store.AuditEvent{Action: action, TargetType: store.ReportTargetUser, TargetId: &target.Id})
+
+	target.IsActive = active
+
+	if err := a.jsonResponse(w, http.StatusOK, target); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
+
+// LogoutUser godoc
+//
+//	@Summary		Signs a user out everywhere
+//	@Description	Revokes every token issued to a user so far, they have to sign in again. Admins can only manage users whose role is at or below their own. Admins only.
+//	@Tags			moderation
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"Tokens revoked"
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID}/logout [post]
+func (a *application) logoutUserHandler(w http.ResponseWriter, r *http.Request) {
+	target := a.getManagedUser(w, r)
+	if target == nil {
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := a.store.Users.RevokeTokens(ctx, target.Id); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			a.NotfoundResponse(w, r, err)
+		default:
+			a.WriteInternalServerError(w, r, err)
+		}
+		return
+	}
+	a.forgetUser(ctx, target.Id)
+
+	a.recordAudit(r, store.AuditEvent{Action: store.AuditTokensRevoked, TargetType: store.ReportTargetUser, TargetId: &target.Id})
+
+	if err := a.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
@@ -18,9 +18,13 @@ import (
 	"github.com/lunatictiol/go-based-social-media/internal/auth"
 	"github.com/lunatictiol/go-based-social-media/internal/env"
 	"github.com/lunatictiol/go-based-social-media/internal/mailer"
+	"github.com/lunatictiol/go-based-social-media/internal/ranking"
 	"github.com/lunatictiol/go-based-social-media/internal/ratelimiter"
+	"github.com/lunatictiol/go-based-social-media/internal/search"
 	"github.com/lunatictiol/go-based-social-media/internal/store"
 	"github.com/lunatictiol/go-based-social-media/internal/store/cache"
+	"github.com/lunatictiol/go-based-social-media/internal/timeline"
+	"github.com/lunatictiol/go-based-social-media/internal/unfurl"
 	httpSwagger "github.com/swaggo/http-swagger/v2"
 	"go.uber.org/zap"
 )
@@ -33,18 +37,32 @@ type application struct {
 	mailer        mailer.Client
 	authenticator auth.Authenticator
 	ratelimiter   ratelimiter.Limiter
+	anonLimiter   ratelimiter.Limiter
+	appealLimiter ratelimiter.Limiter
+	search        search.Backend
+	searchEvents  *search.Dispatcher
+	unfurler      *unfurl.Worker
+	timelines     *timeline.Fanout
+	ranker        ranking.Ranker
 }
 
 type config struct {
-	addr        string
-	apiURL      string
-	db          dbConfig
-	env         string
-	mail        mailConfig
-	frontendURL string
-	auth        authConfig
-	redisConfig redisConfig
-	rateLimiter ratelimiter.Config
+	addr          string
+	apiURL        string
+	db            dbConfig
+	env           string
+	mail          mailConfig
+	frontendURL   string
+	auth          authConfig
+	redisConfig   redisConfig
+	rateLimiter   ratelimiter.Config
+	anonLimiter   ratelimiter.Config
+	appealLimiter ratelimiter.Config
+	jobs          jobsConfig
+	search        searchConfig
+	unfurl        unfurlConfig
+	pagination    paginationConfig
+	feed          feedConfig
 }
 
 type authConfig struct {
@@ -74,6 +92,38 @@ type dbConfig struct {
 	maxIdleTime  string
 }
 
+type jobsConfig struct {
+	purgeInterval    time.Duration
+	publishInterval  time.Duration
+	publishBatchSize int
+}
+
+type searchConfig struct {
+	// backend is either "postgres" or "http" for an external engine
+	backend string
+	url     string
+	apiKey  string
+}
+
+type unfurlConfig struct {
+	enabled bool
+	// queueSize is how many posts can wait for their links to be unfurled
+	queueSize int
+}
+
+type feedConfig struct {
+	// fanout keeps home timelines in Redis, it needs Redis enabled
+	fanout bool
+	// maxFollowers is how many followers an author can have before their
+	// posts are pulled on read instead of fanned out
+	maxFollowers int
+}
+
+type paginationConfig struct {
+	// cursorSecret signs the feed cursors handed to clients
+	cursorSecret string
+}
+
 type redisConfig struct {
 	addr    string
 	pw      string
@@ -94,8 +144,8 @@ func (a *application) mount() http.Handler {
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{env.GetString("CORS_ALLOWED_ORIGIN", "http://localhost:5174")},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "If-None-Match"},
+		ExposedHeaders:   []string{"Link", "ETag"},
 		AllowCredentials: false,
 		MaxAge:           300, // Maximum value not ignored by any of major browsers
 	}))
@@ -112,10 +162,13 @@ func (a *application) mount() http.Handler {
 		docsURL := fmt.Sprintf("%s/swagger/doc.json", a.config.addr)
 		r.Get("/swagger/*", httpSwagger.Handler(httpSwagger.URL(docsURL)))
 
+		r.With(a.OptionalAuthTokenMiddleware, a.AnonymousRateLimiterMiddleware).Get("/explore", a.getExploreHandler)
+
 		//auth
 		r.Route("/authenticate", func(r chi.Router) {
 			r.Post("/register", a.registerUserHandler)
 			r.Post("/login", a.loginUserHandler)
+			r.With(a.AppealRateLimiterMiddleware).Post("/appeal", a.appealSuspensionHandler)
 		})
 
 		//post handler
@@ -128,26 +181,105 @@ func (a *application) mount() http.Handler {
 				r.Get("/", a.getPostHandler)
 				r.Patch("/", a.checkPostOwnership("moderator", a.updatePostHandler))
 				r.Delete("/", a.checkPostOwnership("admin", a.deletePostHandler))
+				r.Post("/repost", a.repostHandler)
+				r.Delete("/repost", a.deleteRepostHandler)
+				r.Post("/quote", a.quotePostHandler)
+				r.Put("/bookmark", a.bookmarkPostHandler)
+				r.Delete("/bookmark", a.unbookmarkPostHandler)
+				r.Put("/pin", a.pinPostHandler)
+				r.Delete("/pin", a.unpinPostHandler)
+				r.Post("/poll/vote", a.votePollHandler)
 
 			})
 		})
 
+		//tag handler
+		r.Route("/tags", func(r chi.Router) {
+			r.With(a.OptionalAuthTokenMiddleware, a.AnonymousRateLimiterMiddleware).Get("/{tag}/posts", a.getTagPostsHandler)
+			r.Group(func(r chi.Router) {
+				r.Use(a.AuthTokenMiddleware)
+				r.Get("/trending", a.getTrendingTagsHandler)
+				r.Put("/{tag}/follow", a.followTagHandler)
+				r.Delete("/{tag}/follow", a.unfollowTagHandler)
+			})
+		})
+
+		//search handler
+		r.Route("/search", func(r chi.Router) {
+			r.Use(a.AuthTokenMiddleware)
+			r.Get("/posts", a.searchPostsHandler)
+			r.Get("/users", a.searchUsersHandler)
+		})
+
+		//moderation handler
+		r.With(a.AuthTokenMiddleware).Post("/reports", a.createReportHandler)
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(a.AuthTokenMiddleware)
+			r.Use(a.requireRoleMiddleware("moderator"))
+			r.Get("/reports", a.getReportQueueHandler)
+			r.Get("/reports/{targetType}/{targetID}", a.getReportedTargetHandler)
+			r.Post("/reports/{targetType}/{targetID}/actions", a.resolveReportsHandler)
+			r.Get("/users", a.listUsersHandler)
+			r.Route("/users/{userID}", func(r chi.Router) {
+				r.Post("/suspension", a.suspendUserHandler)
+				r.Delete("/suspension", a.liftSuspensionHandler)
+				r.Get("/suspensions", a.getSuspensionsHandler)
+				r.Group(func(r chi.Router) {
+					r.Use(a.requireRoleMiddleware("admin"))
+					r.Put("/role", a.setUserRoleHandler)
+					r.Put("/active", a.setUserActiveHandler)
+					r.Post("/logout", a.logoutUserHandler)
+				})
+			})
+			r.Get("/appeals", a.getAppealsHandler)
+			r.Put("/appeals/{appealID}", a.decideAppealHandler)
+			r.With(a.requireRoleMiddleware("admin")).Get("/audit", a.getAuditEventsHandler)
+		})
+
 		//user handler
 		r.Route("/user", func(r chi.Router) {
 
 			r.Put("/activate/{token}", a.activateUserHandler)
+			r.Route("/me", func(r chi.Router) {
+				r.Use(a.AuthTokenMiddleware)
+
+				r.Get("/trash", a.getTrashHandler)
+				r.Put("/trash/{postID}/restore", a.restorePostHandler)
+				r.Get("/drafts", a.getDraftsHandler)
+				r.Get("/scheduled", a.getScheduledHandler)
+				r.Get("/tags", a.getFollowedTagsHandler)
+				r.Get("/bookmarks", a.getBookmarksHandler)
+				r.Get("/warnings", a.getWarningsHandler)
+				r.Route("/collections", func(r chi.Router) {
+					r.Post("/", a.createCollectionHandler)
+					r.Get("/", a.getCollectionsHandler)
+					r.Patch("/{collectionID}", a.updateCollectionHandler)
+					r.Delete("/{collectionID}", a.deleteCollectionHandler)
+				})
+				r.Route("/muted-words", func(r chi.Router) {
+					r.Post("/", a.muteWordHandler)
+					r.Get("/", a.getMutedWordsHandler)
+					r.Delete("/{mutedWordID}", a.unmuteWordHandler)
+				})
+			})
 			r.Route("/{userID}", func(r chi.Router) {
 				r.Use(a.AuthTokenMiddleware)
 
 				r.Get("/", a.getUserHandler)
+				r.Get("/posts", a.getUserPostsHandler)
 				r.Put("/follow", a.followUserHandler)
 				r.Put("/unfollow", a.unfollowUserHandler)
+				r.Get("/collections/{collectionID}", a.getCollectionHandler)
 			})
 
 			//feed handler
 			r.Group(func(r chi.Router) {
 				r.Use(a.AuthTokenMiddleware)
 				r.Get("/feed", a.getUserFeedHandler)
+				r.Get("/feed/for-you", a.getForYouFeedHandler)
+				r.Get("/feed/new-count", a.getFeedNewCountHandler)
+				r.Get("/feed/marker", a.getFeedMarkerHandler)
+				r.Put("/feed/marker", a.setFeedMarkerHandler)
 			})
 		})
 
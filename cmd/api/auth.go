@@ -3,6 +3,7 @@ package main
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
@@ -14,11 +15,14 @@ import (
 )
 
 type RegisterUserPayload struct {
-	Username string `json:"username" validate:"required,max=100"`
-	Email    string `json:"email" validate:"required,email,max=255"`
-	Password string `json:"password" validate:"required,min=8,max=70"`
+	Username    string `json:"username" validate:"required,max=100"`
+	DisplayName string `json:"display_name" validate:"max=100"`
+	Email       string `json:"email" validate:"required,email,max=255"`
+	Password    string `json:"password" validate:"required,min=8,max=70"`
 }
 
+var errInvalidCredentials = errors.New("invalid email or password")
+
 type UserWithToken struct {
 	*store.User
 	Token string `json:"token"`
@@ -53,8 +57,9 @@ func (a *application) registerUserHandler(w http.ResponseWriter, r *http.Request
 	}
 
 	user := &store.User{
-		Username: payload.Username,
-		Email:    payload.Email,
+		Username:    payload.Username,
+		DisplayName: payload.DisplayName,
+		Email:       payload.Email,
 		Role: store.Role{
 			Name: "user",
 		},
@@ -131,6 +136,7 @@ func (a *application) registerUserHandler(w http.ResponseWriter, r *http.Request
 //	@Success		200		{string}	string				"Token"
 //	@Failure		400		{object}	error
 //	@Failure		401		{object}	error
+//	@Failure		403		{object}	error				"Account suspended"
 //	@Failure		500		{object}	error
 //	@Router			/authenticate/login [post]
 func (a *application) loginUserHandler(w http.ResponseWriter, r *http.Request) {
@@ -149,17 +155,37 @@ func (a *application) loginUserHandler(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		switch err {
 		case store.ErrNotFound:
-			a.unauthorisedResponse(w, r, err)
+			a.recordAudit(r, store.AuditEvent{Action: store.AuditLoginFailed, Metadata: map[string]any{"email": payload.Email}})
+			a.unauthorisedResponse(w, r, errInvalidCredentials)
 			return
 		default:
 			a.WriteInternalServerError(w, r, err)
 			return
 		}
 	}
+	if err := user.Password.Compare(payload.Password); err != nil {
+		a.recordAudit(r, store.AuditEvent{
+			Action:     store.AuditLoginFailed,
+			TargetType: store.ReportTargetUser,
+			TargetId:   &user.Id,
+			Metadata:   map[string]any{"email": payload.Email},
+		})
+		a.unauthorisedResponse(w, r, errInvalidCredentials)
+		return
+	}
+
+	// only tell who knows the password about the suspension
+	if err := a.checkSuspension(r.Context(), user.Id); err != nil {
+		a.recordAudit(r, store.AuditEvent{Action: store.AuditLoginSuspended, ActorId: &user.Id, TargetType: store.ReportTargetUser, TargetId: &user.Id})
+		a.authenticationErrorResponse(w, r, err)
+		return
+	}
 	claims := jwt.MapClaims{
 		"sub": user.Id,
 		"exp": time.Now().Add(a.config.auth.token.exp).Unix(),
-		"iat": time.Now().Unix(),
+		// to the microsecond, so a token issued right after a forced logout
+		// isn't mistaken for one it revoked
+		"iat": float64(time.Now().UnixMicro()) / 1e6,
 		"nbf": time.Now().Unix(),
 		"iss": a.config.auth.token.iss,
 		"aud": a.config.auth.token.iss,
@@ -170,6 +196,8 @@ func (a *application) loginUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	a.recordAudit(r, store.AuditEvent{Action: store.AuditLogin, ActorId: &user.Id, TargetType: store.ReportTargetUser, TargetId: &user.Id})
+
 	if err := a.jsonResponse(w, http.StatusCreated, token); err != nil {
 		a.WriteInternalServerError(w, r, err)
 	}
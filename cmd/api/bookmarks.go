@@ -0,0 +1,455 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/lunatictiol/go-based-social-media/internal/store"
+)
+
+type bookmarkPayload struct {
+	// CollectionId files the bookmark under one of the user's collections
+	CollectionId *int64 `json:"collection_id"`
+}
+
+type collectionPayload struct {
+	Name   string `json:"name" validate:"required,max=100"`
+	Shared bool   `json:"shared"`
+}
+
+type updateCollectionPayload struct {
+	Name   *string `json:"name" validate:"omitempty,min=1,max=100"`
+	Shared *bool   `json:"shared"`
+}
+
+type bookmarkPage struct {
+	Collection *store.BookmarkCollection `json:"collection,omitempty"`
+	Bookmarks  []store.Bookmark          `json:"bookmarks"`
+	// NextCursor is passed back as cursor to fetch the next page. It is
+	// empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+type bookmarkListQuery struct {
+	Limit int `validate:"gte=1,lte=50"`
+}
+
+// BookmarkPost godoc
+//
+//	@Summary		Bookmarks a post
+//	@Description	Saves a post for later, optionally in one of the user's collections. Bookmarking an already bookmarked post moves it to the given collection.
+//	@Tags			bookmarks
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int				true	"Post ID"
+//	@Param			payload	body		bookmarkPayload	false	"Bookmark payload"
+//	@Success		204		{string}	string			"Post bookmarked"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/post/{postID}/bookmark [put]
+func (a *application) bookmarkPostHandler(w http.ResponseWriter, r *http.Request) {
+	var payload bookmarkPayload
+	// the body is optional
+	if err := ReadJSON(w, r, &payload); err != nil && !errors.Is(err, io.EOF) {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+
+	post := a.getPostfromCtx(r)
+	if post.Status != store.PostStatusPublished {
+		a.BadRequestResponse(w, r, errors.New("only published posts can be bookmarked"))
+		return
+	}
+
+	user := getUserfromCtx(r)
+
+	err := a.store.Bookmarks.Add(r.Context(), user.Id, sharedPostID(post), payload.CollectionId)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			a.NotfoundResponse(w, r, errors.New("collection not found"))
+		default:
+			a.WriteInternalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := a.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
+
+// UnbookmarkPost godoc
+//
+//	@Summary		Removes a bookmark
+//	@Description	Removes a post from the user's bookmarks
+//	@Tags			bookmarks
+//	@Produce		json
+//	@Param			postID	path		int		true	"Post ID"
+//	@Success		204		{string}	string	"Bookmark removed"
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/post/{postID}/bookmark [delete]
+func (a *application) unbookmarkPostHandler(w http.ResponseWriter, r *http.Request) {
+	post := a.getPostfromCtx(r)
+	user := getUserfromCtx(r)
+
+	if err := a.store.Bookmarks.Remove(r.Context(), user.Id, sharedPostID(post)); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			a.NotfoundResponse(w, r, err)
+		default:
+			a.WriteInternalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := a.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
+
+// GetBookmarks godoc
+//
+//	@Summary		Lists bookmarks
+//	@Description	Lists the user's bookmarks, newest first
+//	@Tags			bookmarks
+//	@Produce		json
+//	@Param			collection	query		int		false	"Only list bookmarks in this collection"
+//	@Param			cursor		query		string	false	"Cursor returned by the previous page"
+//	@Param			limit		query		int		false	"Limit"
+//	@Success		200			{object}	bookmarkPage
+//	@Failure		400			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/user/me/bookmarks [get]
+func (a *application) getBookmarksHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserfromCtx(r)
+
+	bq, err := bookmarkQueryFromRequest(r)
+	if err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+	bq.UserId = user.Id
+
+	if collection := r.URL.Query().Get("collection"); collection != "" {
+		id, err := strconv.ParseInt(collection, 10, 64)
+		if err != nil {
+			a.BadRequestResponse(w, r, err)
+			return
+		}
+		bq.CollectionId = &id
+	}
+
+	a.writeBookmarkPage(w, r, &bookmarkPage{}, bq)
+}
+
+// GetSharedCollection godoc
+//
+//	@Summary		Fetches a bookmark collection
+//	@Description	Fetches a shared bookmark collection and a page of its bookmarks, newest first. Private collections are only visible to their owner.
+//	@Tags			bookmarks
+//	@Produce		json
+//	@Param			userID			path		int		true	"User ID"
+//	@Param			collectionID	path		int		true	"Collection ID"
+//	@Param			cursor			query		string	false	"Cursor returned by the previous page"
+//	@Param			limit			query		int		false	"Limit"
+//	@Success		200				{object}	bookmarkPage
+//	@Failure		400				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/user/{userID}/collections/{collectionID} [get]
+func (a *application) getCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+	collectionID, err := strconv.ParseInt(chi.URLParam(r, "collectionID"), 10, 64)
+	if err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+
+	bq, err := bookmarkQueryFromRequest(r)
+	if err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+
+	collection, err := a.store.Bookmarks.GetCollection(r.Context(), collectionID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			a.NotfoundResponse(w, r, err)
+		default:
+			a.WriteInternalServerError(w, r, err)
+		}
+		return
+	}
+
+	user := getUserfromCtx(r)
+	// private collections answer 404 like missing ones
+	if collection.UserId != userID || (!collection.Shared && collection.UserId != user.Id) {
+		a.NotfoundResponse(w, r, store.ErrNotFound)
+		return
+	}
+
+	bq.UserId = collection.UserId
+	bq.CollectionId = &collection.Id
+
+	a.writeBookmarkPage(w, r, &bookmarkPage{Collection: collection}, bq)
+}
+
+// bookmarkQueryFromRequest reads the limit and cursor query parameters.
+func bookmarkQueryFromRequest(r *http.Request) (store.BookmarkQuery, error) {
+	qs := r.URL.Query()
+
+	lq := bookmarkListQuery{Limit: 20}
+	if limit := qs.Get("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return store.BookmarkQuery{}, err
+		}
+		lq.Limit = l
+	}
+	if err := Validator.Struct(lq); err != nil {
+		return store.BookmarkQuery{}, err
+	}
+
+	bq := store.BookmarkQuery{Limit: lq.Limit}
+	if cursor := qs.Get("cursor"); cursor != "" {
+		var after store.BookmarkCursor
+		if err := decodeCursor(cursor, &after); err != nil {
+			return store.BookmarkQuery{}, err
+		}
+		bq.After = &after
+	}
+
+	return bq, nil
+}
+
+// writeBookmarkPage fetches the page of bookmarks described by bq into page
+// and writes it out. One extra bookmark is fetched to tell whether there is
+// a next page.
+func (a *application) writeBookmarkPage(w http.ResponseWriter, r *http.Request, page *bookmarkPage, bq store.BookmarkQuery) {
+	user := getUserfromCtx(r)
+
+	limit := bq.Limit
+	bq.Limit++
+
+	bookmarks, err := a.store.Bookmarks.Get(r.Context(), user.Id, bq)
+	if err != nil {
+		a.WriteInternalServerError(w, r, err)
+		return
+	}
+
+	if len(bookmarks) > limit {
+		bookmarks = bookmarks[:limit]
+		last := bookmarks[limit-1]
+		page.NextCursor, err = encodeCursor(store.BookmarkCursor{
+			CreatedAt: last.BookmarkedAt,
+			PostId:    last.Post.Id,
+		})
+		if err != nil {
+			a.WriteInternalServerError(w, r, err)
+			return
+		}
+	}
+	page.Bookmarks = bookmarks
+
+	if err := a.jsonResponse(w, http.StatusOK, page); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
+
+// CreateCollection godoc
+//
+//	@Summary		Creates a bookmark collection
+//	@Description	Creates a named bookmark collection. Collections are private unless shared is set.
+//	@Tags			bookmarks
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		collectionPayload	true	"Collection payload"
+//	@Success		201		{object}	store.BookmarkCollection
+//	@Failure		400		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/user/me/collections [post]
+func (a *application) createCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	var payload collectionPayload
+	if err := ReadJSON(w, r, &payload); err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+	if err := Validator.Struct(payload); err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserfromCtx(r)
+
+	collection := &store.BookmarkCollection{
+		UserId: user.Id,
+		Name:   payload.Name,
+		Shared: payload.Shared,
+	}
+
+	if err := a.store.Bookmarks.CreateCollection(r.Context(), collection); err != nil {
+		switch err {
+		case store.ErrConflict:
+			a.conflictResponse(w, r, err)
+		default:
+			a.WriteInternalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := a.jsonResponse(w, http.StatusCreated, collection); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
+
+// GetCollections godoc
+//
+//	@Summary		Lists bookmark collections
+//	@Description	Lists the user's bookmark collections by name
+//	@Tags			bookmarks
+//	@Produce		json
+//	@Success		200	{object}	[]store.BookmarkCollection
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/user/me/collections [get]
+func (a *application) getCollectionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserfromCtx(r)
+
+	collections, err := a.store.Bookmarks.GetCollections(r.Context(), user.Id)
+	if err != nil {
+		a.WriteInternalServerError(w, r, err)
+		return
+	}
+
+	if err := a.jsonResponse(w, http.StatusOK, collections); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
+
+// UpdateCollection godoc
+//
+//	@Summary		Updates a bookmark collection
+//	@Description	Renames a bookmark collection or changes whether it is shared
+//	@Tags			bookmarks
+//	@Accept			json
+//	@Produce		json
+//	@Param			collectionID	path		int						true	"Collection ID"
+//	@Param			payload			body		updateCollectionPayload	true	"Collection payload"
+//	@Success		200				{object}	store.BookmarkCollection
+//	@Failure		400				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		409				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/user/me/collections/{collectionID} [patch]
+func (a *application) updateCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	collectionID, err := strconv.ParseInt(chi.URLParam(r, "collectionID"), 10, 64)
+	if err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+
+	var payload updateCollectionPayload
+	if err := ReadJSON(w, r, &payload); err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+	if err := Validator.Struct(payload); err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserfromCtx(r)
+	ctx := r.Context()
+
+	collection, err := a.store.Bookmarks.GetCollection(ctx, collectionID)
+	if err == nil && collection.UserId != user.Id {
+		err = store.ErrNotFound
+	}
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			a.NotfoundResponse(w, r, err)
+		default:
+			a.WriteInternalServerError(w, r, err)
+		}
+		return
+	}
+
+	if payload.Name != nil {
+		collection.Name = *payload.Name
+	}
+	if payload.Shared != nil {
+		collection.Shared = *payload.Shared
+	}
+
+	if err := a.store.Bookmarks.UpdateCollection(ctx, collection); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			a.NotfoundResponse(w, r, err)
+		case store.ErrConflict:
+			a.conflictResponse(w, r, err)
+		default:
+			a.WriteInternalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := a.jsonResponse(w, http.StatusOK, collection); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
+
+// DeleteCollection godoc
+//
+//	@Summary		Deletes a bookmark collection
+//	@Description	Deletes a bookmark collection. Its bookmarks are kept outside any collection.
+//	@Tags			bookmarks
+//	@Produce		json
+//	@Param			collectionID	path		int		true	"Collection ID"
+//	@Success		200				{string}	string	"collection deleted"
+//	@Failure		400				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/user/me/collections/{collectionID} [delete]
+func (a *application) deleteCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	collectionID, err := strconv.ParseInt(chi.URLParam(r, "collectionID"), 10, 64)
+	if err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserfromCtx(r)
+
+	if err := a.store.Bookmarks.DeleteCollection(r.Context(), user.Id, collectionID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			a.NotfoundResponse(w, r, err)
+		default:
+			a.WriteInternalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := a.jsonResponse(w, http.StatusOK, "collection deleted successfully"); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
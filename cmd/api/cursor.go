@@ -0,0 +1,70 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// encodeCursor turns the position of the last item of a page into the
+// opaque cursor clients pass back to fetch the next page.
+func encodeCursor(position any) (string, error) {
+	b, err := json.Marshal(position)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodeCursor(cursor string, position any) error {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return errInvalidCursor
+	}
+	if err := json.Unmarshal(b, position); err != nil {
+		return errInvalidCursor
+	}
+	return nil
+}
+
+// encodeSignedCursor is encodeCursor followed by an HMAC of the cursor, so
+// clients can't forge positions they were never handed.
+func encodeSignedCursor(position any, secret string) (string, error) {
+	cursor, err := encodeCursor(position)
+	if err != nil {
+		return "", err
+	}
+	return cursor + "." + cursorSignature(cursor, secret), nil
+}
+
+func decodeSignedCursor(cursor string, secret string, position any) error {
+	payload, signature, ok := strings.Cut(cursor, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(cursorSignature(payload, secret))) {
+		return errInvalidCursor
+	}
+	return decodeCursor(payload, position)
+}
+
+func cursorSignature(payload string, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// pageLink builds a Link header entry pointing at the current request with
+// its cursor replaced.
+func pageLink(r *http.Request, cursor string, rel string) string {
+	u := *r.URL
+	qs := u.Query()
+	qs.Set("cursor", cursor)
+	qs.Del("offset")
+	u.RawQuery = qs.Encode()
+	return fmt.Sprintf(`<%s>; rel="%s"`, u.RequestURI(), rel)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/lunatictiol/go-based-social-media/internal/store"
+)
+
+func TestSignedCursorRoundTrip(t *testing.T) {
+	want := feedCursor{PostCursor: store.PostCursor{CreatedAt: "2024-03-01T12:00:00.123456Z", Id: 42}, Before: true}
+
+	cursor, err := encodeSignedCursor(want, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got feedCursor
+	if err := decodeSignedCursor(cursor, "secret", &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("decoded %+v, want %+v", got, want)
+	}
+}
+
+func TestSignedCursorTampering(t *testing.T) {
+	cursor, err := encodeSignedCursor(forYouCursor{At: "2024-03-01T12:00:00Z", Offset: 20}, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, signature, _ := strings.Cut(cursor, ".")
+
+	forged, err := encodeCursor(forYouCursor{At: "2024-03-01T12:00:00Z", Offset: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	flipped := []byte(signature)
+	if flipped[0] == 'A' {
+		flipped[0] = 'B'
+	} else {
+		flipped[0] = 'A'
+	}
+
+	tests := []struct {
+		name   string
+		cursor string
+		secret string
+	}{
+		{"other secret", cursor, "another secret"},
+		{"forged payload", forged + "." + signature, "secret"},
+		{"payload without a signature", forged, "secret"},
+		{"empty signature", payload + ".", "secret"},
+		{"altered signature", payload + "." + string(flipped), "secret"},
+		{"extra segment", cursor + ".x", "secret"},
+		{"empty", "", "secret"},
+		{"garbage", "%%%.%%%", "secret"},
+		{"signed non json", base64.RawURLEncoding.EncodeToString([]byte("not json")) + "." + cursorSignature(base64.RawURLEncoding.EncodeToString([]byte("not json")), "secret"), "secret"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c forYouCursor
+			if err := decodeSignedCursor(tt.cursor, tt.secret, &c); err != errInvalidCursor {
+				t.Errorf("decodeSignedCursor(%q) = %v, %+v, want errInvalidCursor", tt.cursor, err, c)
+			}
+		})
+	}
+}
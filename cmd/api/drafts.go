@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/lunatictiol/go-based-social-media/internal/store"
+)
+
+// GetDrafts godoc
+//
+//	@Summary		Lists draft posts
+//	@Description	Lists the authenticated user's unpublished drafts
+//	@Tags			posts
+//	@Produce		json
+//	@Success		200	{object}	[]store.Post
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/user/me/drafts [get]
+func (a *application) getDraftsHandler(w http.ResponseWriter, r *http.Request) {
+	a.listPostsByStatus(w, r, store.PostStatusDraft)
+}
+
+// GetScheduled godoc
+//
+//	@Summary		Lists scheduled posts
+//	@Description	Lists the authenticated user's posts waiting to be published, soonest first
+//	@Tags			posts
+//	@Produce		json
+//	@Success		200	{object}	[]store.Post
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/user/me/scheduled [get]
+func (a *application) getScheduledHandler(w http.ResponseWriter, r *http.Request) {
+	a.listPostsByStatus(w, r, store.PostStatusScheduled)
+}
+
+func (a *application) listPostsByStatus(w http.ResponseWriter, r *http.Request, status string) {
+	user := getUserfromCtx(r)
+
+	posts, err := a.store.Posts.GetByStatus(r.Context(), user.Id, status)
+	if err != nil {
+		a.WriteInternalServerError(w, r, err)
+		return
+	}
+
+	if err := a.jsonResponse(w, http.StatusOK, posts); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
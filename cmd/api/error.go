@@ -54,3 +54,9 @@ func (a *application) forbiddenResponse(w http.ResponseWriter, r *http.Request)
 
 	WriteJSONError(w, http.StatusForbidden, "forbidden")
 }
+
+func (a *application) suspendedResponse(w http.ResponseWriter, r *http.Request, err error) {
+	a.logger.Warnw("suspended", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+
+	WriteJSONError(w, http.StatusForbidden, err.Error())
+}
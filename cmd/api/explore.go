@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/lunatictiol/go-based-social-media/internal/store"
+)
+
+const (
+	exploreSectionSize = 10
+	// explorePopularWindow is how recent popular posts are
+	explorePopularWindow = time.Hour * 24
+	// exploreTags is how many trending tags get a section
+	exploreTags        = 5
+	exploreTagPostSize = 5
+)
+
+// GetExplore godoc
+//
+//	@Summary		Fetches the explore page
+//	@Description	Fetches recent and popular public posts across all users, with a section for each trending tag. It can be read without signing in, anonymous requests have their own, stricter rate limit. The page is the same for everyone and can be up to 30 seconds old.
+//	@Tags			feed
+//	@Produce		json
+//	@Success		200	{object}	store.ExplorePage
+//	@Failure		401	{object}	error
+//	@Failure		429	{object}	error
+//	@Failure		500	{object}	error
+//	@Router			/explore [get]
+func (a *application) getExploreHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if a.config.redisConfig.enabled {
+		page, err := a.cacheStorage.Explore.Get(ctx)
+		if err != nil {
+			a.logger.Warnw("error reading cached explore page", "error", err)
+		}
+		if page != nil {
+			if err := a.jsonResponse(w, http.StatusOK, page); err != nil {
+				a.WriteInternalServerError(w, r, err)
+			}
+			return
+		}
+	}
+
+	page, err := a.buildExplorePage(ctx)
+	if err != nil {
+		a.WriteInternalServerError(w, r, err)
+		return
+	}
+
+	if a.config.redisConfig.enabled {
+		if err := a.cacheStorage.Explore.Set(ctx, page); err != nil {
+			a.logger.Warnw("error caching explore page", "error", err)
+		}
+	}
+
+	if err := a.jsonResponse(w, http.StatusOK, page); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
+
+func (a *application) buildExplorePage(ctx context.Context) (*store.ExplorePage, error) {
+	page := &store.ExplorePage{
+		Tags:        []store.ExploreSection{},
+		GeneratedAt: time.Now().UTC(),
+	}
+
+	var err error
+	page.Recent, err = a.store.Posts.GetPublicRecent(ctx, exploreSectionSize)
+	if err != nil {
+		return nil, err
+	}
+
+	page.Popular, err = a.store.Posts.GetPublicPopular(ctx, page.GeneratedAt.Add(-explorePopularWindow), exploreSectionSize)
+	if err != nil {
+		return nil, err
+	}
+
+	window := trendingWindows["24h"]
+	trending, err := a.store.Tags.Trending(ctx, window.window, window.baseline, exploreTags)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tag := range trending {
+		// no viewer, tag timelines only hold public posts
+		posts, err := a.store.Tags.GetPosts(ctx, tag.Tag, 0, store.PaginatedFeedQuery{Limit: exploreTagPostSize})
+		if err != nil {
+			return nil, err
+		}
+		page.Tags = append(page.Tags, store.ExploreSection{Tag: tag.Tag, Posts: posts})
+	}
+
+	return page, nil
+}
@@ -1,12 +1,50 @@
 package main
 
 import (
-	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/lunatictiol/go-based-social-media/internal/ranking"
 	"github.com/lunatictiol/go-based-social-media/internal/store"
 )
 
+// feedCursor is the position a feed page continues from. Cursors are
+// signed, clients only pass back the ones they were given.
+type feedCursor struct {
+	store.PostCursor
+	// Before walks back towards the start of the feed
+	Before bool `json:"b,omitempty"`
+}
+
+type feedPage struct {
+	Posts []store.PostMetaData `json:"posts"`
+	// NextCursor and PrevCursor are passed back as cursor to fetch the page
+	// after or before this one. They are also sent in the Link header and
+	// left empty when there is nothing to fetch that way.
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
+// GetUserFeed godoc
+//
+//	@Summary		Fetches the user feed
+//	@Description	Fetches the posts of the users and tags the user follows. Pages are cursor based so posts published while paging don't cause duplicates or gaps. The first page's prev_cursor fetches what was published since.
+//	@Tags			feed
+//	@Produce		json
+//	@Param			cursor	query		string	false	"next_cursor or prev_cursor of a previous page"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			sort	query		string	false	"desc (default) or asc"
+//	@Param			tags	query		string	false	"Comma separated tags"
+//	@Param			search	query		string	false	"Search"
+//	@Param			If-None-Match	header	string	false	"ETag of a previous response"
+//	@Success		200		{object}	feedPage
+//	@Success		304		"The feed didn't change"
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/user/feed [get]
 func (a *application) getUserFeedHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
@@ -25,17 +63,218 @@ func (a *application) getUserFeedHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		c, err := a.decodeFeedCursor(cursor)
+		if err != nil {
+			a.BadRequestResponse(w, r, err)
+			return
+		}
+		if c.Before {
+			fq.Before = &c.PostCursor
+		} else {
+			fq.After = &c.PostCursor
+		}
+	}
+
 	user := getUserfromCtx(r)
-	log.Printf("user ; %v", user)
 
-	feed, err := a.store.Posts.GetUserFeed(ctx, user.Id, fq)
+	// one extra post tells whether there is more in the direction paged
+	limit := fq.Limit
+	fq.Limit++
+
+	var posts []store.PostMetaData
+	if a.timelines != nil {
+		posts, err = a.timelines.Feed(ctx, user.Id, fq)
+	} else {
+		posts, err = a.store.Posts.GetUserFeed(ctx, user.Id, fq)
+	}
 	if err != nil {
 		a.WriteInternalServerError(w, r, err)
 		return
 	}
-	log.Printf("feed ; %v", feed)
-	if err = a.jsonResponse(w, http.StatusOK, feed); err != nil {
+
+	forward := fq.Before == nil
+	more := len(posts) > limit
+	if more {
+		if forward {
+			posts = posts[:limit]
+		} else {
+			// pages walked backwards come in feed order, the extra post is
+			// the first one
+			posts = posts[1:]
+		}
+	}
+
+	page := feedPage{Posts: posts}
+	var links []string
+
+	if len(posts) > 0 {
+		first, last := posts[0].Post, posts[len(posts)-1].Post
+
+		// the page a cursor came from is always there to go back to
+		if !forward || more {
+			page.NextCursor, err = encodeSignedCursor(feedCursor{
+				PostCursor: store.PostCursor{CreatedAt: last.CreatedAt, Id: last.Id},
+			}, a.config.pagination.cursorSecret)
+			if err != nil {
+				a.WriteInternalServerError(w, r, err)
+				return
+			}
+			links = append(links, pageLink(r, page.NextCursor, "next"))
+		}
+		if forward || more {
+			page.PrevCursor, err = encodeSignedCursor(feedCursor{
+				PostCursor: store.PostCursor{CreatedAt: first.CreatedAt, Id: first.Id},
+				Before:     true,
+			}, a.config.pagination.cursorSecret)
+			if err != nil {
+				a.WriteInternalServerError(w, r, err)
+				return
+			}
+			links = append(links, pageLink(r, page.PrevCursor, "prev"))
+		}
+	}
+
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+
+	if err = a.jsonResponseWithETag(w, r, http.StatusOK, page); err != nil {
 		a.WriteInternalServerError(w, r, err)
 		return
 	}
 }
+
+func (a *application) decodeFeedCursor(cursor string) (feedCursor, error) {
+	var c feedCursor
+	if err := decodeSignedCursor(cursor, a.config.pagination.cursorSecret, &c); err != nil {
+		return feedCursor{}, err
+	}
+	if _, err := time.Parse(time.RFC3339Nano, c.CreatedAt); err != nil {
+		return feedCursor{}, errInvalidCursor
+	}
+	return c, nil
+}
+
+const (
+	// forYouWindow is how far back the For You feed looks for posts
+	forYouWindow = time.Hour * 72
+	// forYouCandidates is how many of the newest posts inside the window
+	// get ranked
+	forYouCandidates = 500
+)
+
+// forYouCursor replays the ranking a page came from as of the time of the
+// first page, leaving out posts created since. Engagement and interaction
+// counts are read again for every page though, so posts whose counts
+// changed in between can move across pages: show up twice or be skipped.
+type forYouCursor struct {
+	At     string `json:"t"`
+	Offset int    `json:"o"`
+}
+
+type forYouQuery struct {
+	Limit int `validate:"gte=1,lte=20"`
+	// Debug explains how each post was ranked
+	Debug bool
+}
+
+type rankedPost struct {
+	store.PostMetaData
+	Explanation *ranking.Explanation `json:"explanation,omitempty"`
+}
+
+type forYouPage struct {
+	Posts      []rankedPost `json:"posts"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
+
+// GetForYouFeed godoc
+//
+//	@Summary		Fetches the ranked For You feed
+//	@Description	Fetches the posts of the last 72 hours from the users and tags the user follows, ranked by recency, engagement (comments, reposts and bookmarks), the user's past interactions with the author and followed tags. Posts have no reactions. With debug each post explains its score. Pages are ranked as of the time of the first page, but with the engagement of the moment they're fetched, so a post can move from one page to another while paging.
+//	@Tags			feed
+//	@Produce		json
+//	@Param			cursor	query		string	false	"next_cursor of the previous page"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			debug	query		bool	false	"Explain the ranking of each post"
+//	@Success		200		{object}	forYouPage
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/user/feed/for-you [get]
+func (a *application) getForYouFeedHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	fq := forYouQuery{Limit: 20}
+	if limit := qs.Get("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			a.BadRequestResponse(w, r, err)
+			return
+		}
+		fq.Limit = l
+	}
+	if debug := qs.Get("debug"); debug != "" {
+		d, err := strconv.ParseBool(debug)
+		if err != nil {
+			a.BadRequestResponse(w, r, err)
+			return
+		}
+		fq.Debug = d
+	}
+	if err := Validator.Struct(fq); err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+
+	cursor := forYouCursor{At: time.Now().UTC().Format(time.RFC3339Nano)}
+	if c := qs.Get("cursor"); c != "" {
+		if err := decodeSignedCursor(c, a.config.pagination.cursorSecret, &cursor); err != nil {
+			a.BadRequestResponse(w, r, err)
+			return
+		}
+	}
+	now, err := time.Parse(time.RFC3339Nano, cursor.At)
+	if err != nil || cursor.Offset < 0 {
+		a.BadRequestResponse(w, r, errInvalidCursor)
+		return
+	}
+
+	user := getUserfromCtx(r)
+
+	candidates, err := a.store.Posts.GetFeedCandidates(r.Context(), user.Id, store.CandidateQuery{
+		Since: now.Add(-forYouWindow),
+		Until: now,
+		Limit: forYouCandidates,
+	})
+	if err != nil {
+		a.WriteInternalServerError(w, r, err)
+		return
+	}
+
+	ranked := ranking.Rank(a.ranker, candidates, now)
+
+	page := forYouPage{Posts: []rankedPost{}}
+	end := min(cursor.Offset+fq.Limit, len(ranked))
+	for i := min(cursor.Offset, end); i < end; i++ {
+		post := rankedPost{PostMetaData: ranked[i].PostMetaData}
+		if fq.Debug {
+			post.Explanation = &ranked[i].Score.Explanation
+		}
+		page.Posts = append(page.Posts, post)
+	}
+
+	if end < len(ranked) {
+		page.NextCursor, err = encodeSignedCursor(forYouCursor{At: cursor.At, Offset: end}, a.config.pagination.cursorSecret)
+		if err != nil {
+			a.WriteInternalServerError(w, r, err)
+			return
+		}
+		w.Header().Set("Link", pageLink(r, page.NextCursor, "next"))
+	}
+
+	if err := a.jsonResponse(w, http.StatusOK, page); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/lunatictiol/go-based-social-media/internal/search"
+	"github.com/lunatictiol/go-based-social-media/internal/timeline"
+)
+
+// purgeDeletedPosts periodically removes posts whose trash retention has
+// expired. It runs until ctx is cancelled.
+func (a *application) purgeDeletedPosts(ctx context.Context) {
+	ticker := time.NewTicker(a.config.jobs.purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := a.store.Posts.PurgeDeleted(ctx)
+			if err != nil {
+				a.logger.Errorw("error purging deleted posts", "error", err)
+				continue
+			}
+			if purged > 0 {
+				a.logger.Infow("purged deleted posts", "count", purged)
+			}
+		}
+	}
+}
+
+// publishScheduledPosts publishes scheduled posts once their publish_at has
+// passed. Several API instances can run it at the same time, PublishDue
+// makes sure each post is only claimed once.
+func (a *application) publishScheduledPosts(ctx context.Context) {
+	ticker := time.NewTicker(a.config.jobs.publishInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				posts, err := a.store.Posts.PublishDue(ctx, a.config.jobs.publishBatchSize)
+				if err != nil {
+					a.logger.Errorw("error publishing scheduled posts", "error", err)
+					break
+				}
+				if len(posts) > 0 {
+					a.logger.Infow("published scheduled posts", "count", len(posts))
+				}
+				for _, post := range posts {
+					a.searchEvents.Publish(search.Event{Type: search.PostChanged, ID: post.Id})
+					a.timelines.Publish(timeline.Event{Type: timeline.PostPublished, ID: post.Id})
+				}
+				if len(posts) < a.config.jobs.publishBatchSize {
+					break
+				}
+			}
+		}
+	}
+}
@@ -1,8 +1,12 @@
 package main
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"net/http"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
 )
@@ -48,3 +52,46 @@ func (a *application) jsonResponse(w http.ResponseWriter, status int, data any)
 		Data:    data,
 	})
 }
+
+// jsonResponseWithETag writes data like jsonResponse, tagged with an ETag of
+// the body. Clients sending it back in If-None-Match get a 304 Not Modified
+// without a body while nothing changed.
+func (a *application) jsonResponseWithETag(w http.ResponseWriter, r *http.Request, status int, data any) error {
+	type envolope struct {
+		Success bool `json:"success"`
+		Data    any  `json:"data"`
+	}
+
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(&envolope{Success: true, Data: data}); err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(body.Bytes())
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err := w.Write(body.Bytes())
+
+	return err
+}
+
+// etagMatches reports whether an If-None-Match header holds etag, comparing
+// weakly as RFC 9110 asks for.
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
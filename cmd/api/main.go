@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"expvar"
 	"runtime"
 	"time"
@@ -11,9 +12,13 @@ import (
 	"github.com/lunatictiol/go-based-social-media/internal/db"
 	"github.com/lunatictiol/go-based-social-media/internal/env"
 	"github.com/lunatictiol/go-based-social-media/internal/mailer"
+	"github.com/lunatictiol/go-based-social-media/internal/ranking"
 	"github.com/lunatictiol/go-based-social-media/internal/ratelimiter"
+	"github.com/lunatictiol/go-based-social-media/internal/search"
 	"github.com/lunatictiol/go-based-social-media/internal/store"
 	"github.com/lunatictiol/go-based-social-media/internal/store/cache"
+	"github.com/lunatictiol/go-based-social-media/internal/timeline"
+	"github.com/lunatictiol/go-based-social-media/internal/unfurl"
 	"go.uber.org/zap"
 )
 
@@ -61,6 +66,18 @@ func main() {
 	if err != nil {
 		logger.Fatal("Error loading .env file")
 	}
+	ANON_RATELIMITER_REQUESTS_COUNT, err := env.GetInt("ANON_RATELIMITER_REQUESTS_COUNT", 5)
+	if err != nil {
+		logger.Fatal("Error loading .env file")
+	}
+	APPEAL_RATELIMITER_REQUESTS_COUNT, err := env.GetInt("APPEAL_RATELIMITER_REQUESTS_COUNT", 3)
+	if err != nil {
+		logger.Fatal("Error loading .env file")
+	}
+	fanoutMaxFollowers, err := env.GetInt("FEED_FANOUT_MAX_FOLLOWERS", 10000)
+	if err != nil {
+		logger.Fatal("Error loading .env file")
+	}
 	RATELIMITER_REQUESTS_COUNT, err := env.GetInt("RATELIMITER_REQUESTS_COUNT", 20)
 	if err != nil {
 		logger.Fatal("Error loading .env file")
@@ -104,6 +121,37 @@ func main() {
 			TimeFrame:            time.Second * 5,
 			Enabled:              env.GetBool("RATE_LIMITER_ENABLED", true),
 		},
+		anonLimiter: ratelimiter.Config{
+			RequestsPerTimeFrame: ANON_RATELIMITER_REQUESTS_COUNT,
+			TimeFrame:            time.Second * 5,
+			Enabled:              env.GetBool("RATE_LIMITER_ENABLED", true),
+		},
+		appealLimiter: ratelimiter.Config{
+			RequestsPerTimeFrame: APPEAL_RATELIMITER_REQUESTS_COUNT,
+			TimeFrame:            time.Minute * 15,
+			Enabled:              env.GetBool("RATE_LIMITER_ENABLED", true),
+		},
+		search: searchConfig{
+			backend: env.GetString("SEARCH_BACKEND", "postgres"),
+			url:     env.GetString("SEARCH_URL", "http://localhost:7700"),
+			apiKey:  env.GetString("SEARCH_APIKEY", ""),
+		},
+		unfurl: unfurlConfig{
+			enabled:   env.GetBool("UNFURL_ENABLED", true),
+			queueSize: 1000,
+		},
+		feed: feedConfig{
+			fanout:       env.GetBool("FEED_FANOUT_ENABLED", true),
+			maxFollowers: fanoutMaxFollowers,
+		},
+		pagination: paginationConfig{
+			cursorSecret: env.GetString("CURSOR_SECRET", "example"),
+		},
+		jobs: jobsConfig{
+			purgeInterval:    time.Hour,
+			publishInterval:  time.Second * 30,
+			publishBatchSize: 100,
+		},
 	}
 	logger.Info("connecting to database")
 	db, err := db.New(cfg.db.addr, cfg.db.maxOpenConns, cfg.db.maxIdleConns, cfg.db.maxIdleTime)
@@ -140,6 +188,46 @@ func main() {
 		cfg.rateLimiter.RequestsPerTimeFrame,
 		cfg.rateLimiter.TimeFrame,
 	)
+	anonLimiter := ratelimiter.NewFixedWindowLimiter(
+		cfg.anonLimiter.RequestsPerTimeFrame,
+		cfg.anonLimiter.TimeFrame,
+	)
+	appealLimiter := ratelimiter.NewFixedWindowLimiter(
+		cfg.appealLimiter.RequestsPerTimeFrame,
+		cfg.appealLimiter.TimeFrame,
+	)
+	// Search
+	var searchBackend search.Backend = search.NewPostgresBackend(store)
+	var searchEvents *search.Dispatcher
+	if cfg.search.backend == "http" {
+		httpBackend := search.NewHTTPBackend(cfg.search.url, cfg.search.apiKey, store)
+		if err := httpBackend.EnsureIndexes(context.Background()); err != nil {
+			logger.Fatalf("Error configuring search indexes :%v", err)
+		}
+		searchBackend = httpBackend
+		searchEvents = search.NewDispatcher(httpBackend, store, logger, 1000)
+		logger.Infow("search engine configured", "url", cfg.search.url)
+	}
+
+	// Link previews
+	var unfurlWorker *unfurl.Worker
+	if cfg.unfurl.enabled {
+		var previewCache unfurl.Cache
+		if cfg.redisConfig.enabled {
+			previewCache = cacheStorage.LinkPreviews
+		}
+		unfurlWorker = unfurl.NewWorker(unfurl.New(unfurl.DefaultConfig()), store, previewCache, logger, cfg.unfurl.queueSize)
+	}
+
+	// Home timelines
+	var timelines *timeline.Fanout
+	if cfg.feed.fanout && cfg.redisConfig.enabled {
+		timelines = timeline.NewFanout(store, cacheStorage.Timelines, timeline.Config{
+			MaxFollowers: cfg.feed.maxFollowers,
+			Length:       cache.TimelineLength,
+		}, logger, 1000)
+	}
+
 	app := &application{
 		config:        cfg,
 		store:         store,
@@ -148,6 +236,13 @@ func main() {
 		mailer:        mailer,
 		authenticator: jwtAuthenticator,
 		ratelimiter:   rateLimiter,
+		anonLimiter:   anonLimiter,
+		appealLimiter: appealLimiter,
+		search:        searchBackend,
+		searchEvents:  searchEvents,
+		unfurler:      unfurlWorker,
+		timelines:     timelines,
+		ranker:        ranking.NewWeightedRanker(ranking.DefaultWeights()),
 	}
 	// Metrics collected
 	expvar.NewString("version").Set(version)
@@ -158,6 +253,21 @@ func main() {
 		return runtime.NumGoroutine()
 	}))
 
+	// Background jobs
+	jobsCtx, cancelJobs := context.WithCancel(context.Background())
+	defer cancelJobs()
+	go app.purgeDeletedPosts(jobsCtx)
+	go app.publishScheduledPosts(jobsCtx)
+	if searchEvents != nil {
+		go searchEvents.Run(jobsCtx)
+	}
+	if unfurlWorker != nil {
+		go unfurlWorker.Run(jobsCtx)
+	}
+	if timelines != nil {
+		go timelines.Run(jobsCtx)
+	}
+
 	mux := app.mount()
 	logger.Fatal(app.run(mux))
 }
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/lunatictiol/go-based-social-media/internal/store"
+)
+
+// maxNewCount bounds how far new posts are counted, clients show more as
+// "1000+".
+const maxNewCount = 1000
+
+type feedNewCount struct {
+	Count int `json:"count"`
+	// More is set when there are more than Count new posts
+	More bool `json:"more"`
+}
+
+type feedMarker struct {
+	// Cursor points at the newest post seen. Passed as cursor to the feed
+	// it fetches the posts published since.
+	Cursor    string `json:"cursor"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type feedMarkerPayload struct {
+	Cursor string `json:"cursor" validate:"required"`
+}
+
+// GetFeedNewCount godoc
+//
+//	@Summary		Counts new feed posts
+//	@Description	Counts the feed posts newer than since_cursor, any cursor returned by the feed, or than the user's read marker when it is left out. Counting stops at 1000.
+//	@Tags			feed
+//	@Produce		json
+//	@Param			since_cursor	query		string	false	"Feed cursor to count from"
+//	@Success		200				{object}	feedNewCount
+//	@Failure		400				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/user/feed/new-count [get]
+func (a *application) getFeedNewCountHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := getUserfromCtx(r)
+
+	// without a marker every post is new
+	since := store.PostCursor{CreatedAt: "1970-01-01T00:00:00Z"}
+
+	if cursor := r.URL.Query().Get("since_cursor"); cursor != "" {
+		c, err := a.decodeFeedCursor(cursor)
+		if err != nil {
+			a.BadRequestResponse(w, r, err)
+			return
+		}
+		since = c.PostCursor
+	} else {
+		marker, err := a.store.FeedMarkers.Get(ctx, user.Id)
+		switch err {
+		case nil:
+			since = marker.Position
+		case store.ErrNotFound:
+		default:
+			a.WriteInternalServerError(w, r, err)
+			return
+		}
+	}
+
+	count, err := a.store.Posts.CountFeedSince(ctx, user.Id, since, maxNewCount+1)
+	if err != nil {
+		a.WriteInternalServerError(w, r, err)
+		return
+	}
+
+	res := feedNewCount{Count: min(count, maxNewCount), More: count > maxNewCount}
+	if err := a.jsonResponse(w, http.StatusOK, res); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
+
+// GetFeedMarker godoc
+//
+//	@Summary		Fetches the feed read marker
+//	@Description	Fetches the newest feed post the user has seen on any of their devices
+//	@Tags			feed
+//	@Produce		json
+//	@Success		200	{object}	feedMarker
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/user/feed/marker [get]
+func (a *application) getFeedMarkerHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserfromCtx(r)
+
+	marker, err := a.store.FeedMarkers.Get(r.Context(), user.Id)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			a.NotfoundResponse(w, r, err)
+		default:
+			a.WriteInternalServerError(w, r, err)
+		}
+		return
+	}
+
+	a.writeFeedMarker(w, r, marker)
+}
+
+// SetFeedMarker godoc
+//
+//	@Summary		Moves the feed read marker
+//	@Description	Marks the feed as seen up to the post a feed cursor points at. The marker only moves forward, the response holds the stored marker.
+//	@Tags			feed
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		feedMarkerPayload	true	"Feed cursor"
+//	@Success		200		{object}	feedMarker
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/user/feed/marker [put]
+func (a *application) setFeedMarkerHandler(w http.ResponseWriter, r *http.Request) {
+	var payload feedMarkerPayload
+	if err := ReadJSON(w, r, &payload); err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+	if err := Validator.Struct(payload); err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+
+	c, err := a.decodeFeedCursor(payload.Cursor)
+	if err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserfromCtx(r)
+	marker := &store.FeedMarker{UserId: user.Id, Position: c.PostCursor}
+
+	if err := a.store.FeedMarkers.Set(r.Context(), marker); err != nil {
+		a.WriteInternalServerError(w, r, err)
+		return
+	}
+
+	a.writeFeedMarker(w, r, marker)
+}
+
+func (a *application) writeFeedMarker(w http.ResponseWriter, r *http.Request, marker *store.FeedMarker) {
+	cursor, err := encodeSignedCursor(feedCursor{PostCursor: marker.Position, Before: true}, a.config.pagination.cursorSecret)
+	if err != nil {
+		a.WriteInternalServerError(w, r, err)
+		return
+	}
+
+	if err := a.jsonResponse(w, http.StatusOK, feedMarker{Cursor: cursor, UpdatedAt: marker.UpdatedAt}); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -53,40 +54,94 @@ func (a *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			a.unauthorisedResponse(w, r, fmt.Errorf("authorization header is malformed"))
-			return
-		}
-
-		token := parts[1]
-		jwtToken, err := a.authenticator.ValidateToken(token)
+		user, err := a.authenticateToken(r.Context(), authHeader)
 		if err != nil {
-			a.unauthorisedResponse(w, r, err)
+			a.authenticationErrorResponse(w, r, err)
 			return
 		}
 
-		claims, _ := jwtToken.Claims.(jwt.MapClaims)
+		ctx := context.WithValue(r.Context(), userKey, user)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
 
-		userID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
-		if err != nil {
-			a.unauthorisedResponse(w, r, err)
+// OptionalAuthTokenMiddleware authenticates requests carrying a token like
+// AuthTokenMiddleware and lets the others through anonymously, without a
+// user in their context.
+func (a *application) OptionalAuthTokenMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeader := r.Header.Get("Authorization")
+		if authHeader == "" {
+			next.ServeHTTP(w, r)
 			return
 		}
 
-		ctx := r.Context()
-
-		user, err := a.getUser(ctx, userID)
+		user, err := a.authenticateToken(r.Context(), authHeader)
 		if err != nil {
-			a.unauthorisedResponse(w, r, err)
+			a.authenticationErrorResponse(w, r, err)
 			return
 		}
 
-		ctx = context.WithValue(ctx, userKey, user)
+		ctx := context.WithValue(r.Context(), userKey, user)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+var errTokenRevoked = errors.New("token has been revoked, sign in again")
+
+// authenticateToken returns the user of the bearer token in authHeader.
+// Suspended users get a *suspendedError.
+func (a *application) authenticateToken(ctx context.Context, authHeader string) (*store.User, error) {
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return nil, fmt.Errorf("authorization header is malformed")
+	}
+
+	token := parts[1]
+	jwtToken, err := a.authenticator.ValidateToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, _ := jwtToken.Claims.(jwt.MapClaims)
+
+	userID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := a.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// tokens issued before a forced logout are revoked. Both times have
+	// microsecond precision, tokens issued with a whole second iat before
+	// that are only let through from the second after the logout.
+	if user.TokensValidAfter != nil {
+		iat, _ := claims["iat"].(float64)
+		if iat < float64(user.TokensValidAfter.UnixMicro())/1e6 {
+			return nil, errTokenRevoked
+		}
+	}
+
+	if err := a.checkSuspension(ctx, user.Id); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (a *application) authenticationErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	var suspended *suspendedError
+	if errors.As(err, &suspended) {
+		a.suspendedResponse(w, r, err)
+		return
+	}
+
+	a.unauthorisedResponse(w, r, err)
+}
+
 func (a *application) getUser(ctx context.Context, userID int64) (*store.User, error) {
 	if !a.config.redisConfig.enabled {
 		return a.store.Users.GetUserByID(ctx, userID)
@@ -111,6 +166,14 @@ func (a *application) getUser(ctx context.Context, userID int64) (*store.User, e
 	return user, nil
 }
 
+// forgetUser drops the cached copy of a user whose role, status or tokens
+// changed, so authentication sees the change right away.
+func (a *application) forgetUser(ctx context.Context, userID int64) {
+	if a.config.redisConfig.enabled {
+		a.cacheStorage.Users.Delete(ctx, userID)
+	}
+}
+
 func (a *application) RateLimiterMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if a.config.rateLimiter.Enabled {
@@ -124,6 +187,43 @@ func (a *application) RateLimiterMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// AnonymousRateLimiterMiddleware rate limits requests without a user in
+// their context with the stricter anonymous limiter, authenticated ones go
+// through the regular limiter.
+func (a *application) AnonymousRateLimiterMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limiter, enabled := a.ratelimiter, a.config.rateLimiter.Enabled
+		if getUserfromCtx(r) == nil {
+			limiter, enabled = a.anonLimiter, a.config.anonLimiter.Enabled
+		}
+
+		if enabled {
+			if allow, retryAfter := limiter.Allow(r.RemoteAddr); !allow {
+				a.rateLimitExceededResponse(w, r, retryAfter.String())
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AppealRateLimiterMiddleware rate limits suspension appeals with their own
+// strict limiter, as they take credentials and would otherwise allow
+// guessing passwords of suspended accounts.
+func (a *application) AppealRateLimiterMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.config.appealLimiter.Enabled {
+			if allow, retryAfter := a.appealLimiter.Allow(r.RemoteAddr); !allow {
+				a.rateLimitExceededResponse(w, r, retryAfter.String())
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (a *application) checkPostOwnership(requiredRole string, next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user := getUserfromCtx(r)
@@ -149,6 +249,27 @@ func (a *application) checkPostOwnership(requiredRole string, next http.HandlerF
 	})
 }
 
+// requireRoleMiddleware lets through the users whose role is at least
+// requiredRole.
+func (a *application) requireRoleMiddleware(requiredRole string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			allowed, err := a.checkRolePrecedence(r.Context(), getUserfromCtx(r), requiredRole)
+			if err != nil {
+				a.WriteInternalServerError(w, r, err)
+				return
+			}
+
+			if !allowed {
+				a.forbiddenResponse(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func (app *application) checkRolePrecedence(ctx context.Context, user *store.User, roleName string) (bool, error) {
 	role, err := app.store.Roles.GetByName(ctx, roleName)
 	if err != nil {
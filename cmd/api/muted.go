@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/lunatictiol/go-based-social-media/internal/store"
+)
+
+type mutedWordPayload struct {
+	Phrase string `json:"phrase" validate:"required,max=100"`
+	// WholeWord defaults to true
+	WholeWord *bool      `json:"whole_word"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// MuteWord godoc
+//
+//	@Summary		Mutes a word or phrase
+//	@Description	Hides the posts and comments containing a word or phrase, in any case, from the user's feeds, search results and comment lists. Whole word mutes, the default, don't match the phrase inside longer words. A mute without expires_at lasts until it is removed. Muting a phrase again updates it.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		mutedWordPayload	true	"Muted word"
+//	@Success		201		{object}	store.MutedWord
+//	@Failure		400		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/user/me/muted-words [post]
+func (a *application) muteWordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload mutedWordPayload
+	if err := ReadJSON(w, r, &payload); err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+	if err := Validator.Struct(payload); err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+
+	if strings.TrimSpace(payload.Phrase) == "" {
+		a.BadRequestResponse(w, r, errors.New("phrase can't be blank"))
+		return
+	}
+	if payload.ExpiresAt != nil && !payload.ExpiresAt.After(time.Now()) {
+		a.BadRequestResponse(w, r, errors.New("expires_at must be in the future"))
+		return
+	}
+
+	user := getUserfromCtx(r)
+	word := &store.MutedWord{
+		UserId:    user.Id,
+		Phrase:    payload.Phrase,
+		WholeWord: payload.WholeWord == nil || *payload.WholeWord,
+		ExpiresAt: payload.ExpiresAt,
+	}
+
+	if err := a.store.MutedWords.Create(r.Context(), word); err != nil {
+		switch err {
+		case store.ErrMuteLimit:
+			a.conflictResponse(w, r, err)
+		default:
+			a.WriteInternalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := a.jsonResponse(w, http.StatusCreated, word); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
+
+// GetMutedWords godoc
+//
+//	@Summary		Lists muted words
+//	@Description	Lists the words and phrases the user has muted that haven't expired, most recent first
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{array}		store.MutedWord
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/user/me/muted-words [get]
+func (a *application) getMutedWordsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserfromCtx(r)
+
+	words, err := a.store.MutedWords.GetByUser(r.Context(), user.Id)
+	if err != nil {
+		a.WriteInternalServerError(w, r, err)
+		return
+	}
+
+	if err := a.jsonResponse(w, http.StatusOK, words); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
+
+// UnmuteWord godoc
+//
+//	@Summary		Unmutes a word or phrase
+//	@Description	Removes one of the user's muted words
+//	@Tags			users
+//	@Produce		json
+//	@Param			mutedWordID	path		int		true	"Muted word ID"
+//	@Success		204			{string}	string	"Muted word removed"
+//	@Failure		400			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/user/me/muted-words/{mutedWordID} [delete]
+func (a *application) unmuteWordHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "mutedWordID"), 10, 64)
+	if err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserfromCtx(r)
+
+	if err := a.store.MutedWords.Delete(r.Context(), user.Id, id); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			a.NotfoundResponse(w, r, err)
+		default:
+			a.WriteInternalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := a.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
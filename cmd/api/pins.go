@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/lunatictiol/go-based-social-media/internal/store"
+)
+
+type pinPayload struct {
+	// Position is where the post goes among the pins, counted from 1. The
+	// post is pinned last when it is left out.
+	Position int `json:"position" validate:"omitempty,min=1,max=3"`
+}
+
+// PinPost godoc
+//
+//	@Summary		Pins a post
+//	@Description	Pins one of the user's published posts to their profile. Up to three posts can be pinned; pinning a pinned post moves it.
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int			true	"Post ID"
+//	@Param			payload	body		pinPayload	false	"Pin payload"
+//	@Success		204		{string}	string		"Post pinned"
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/post/{postID}/pin [put]
+func (a *application) pinPostHandler(w http.ResponseWriter, r *http.Request) {
+	var payload pinPayload
+	// the body is optional
+	if err := ReadJSON(w, r, &payload); err != nil && !errors.Is(err, io.EOF) {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+	if err := Validator.Struct(payload); err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+
+	post := a.getPostfromCtx(r)
+	user := getUserfromCtx(r)
+	if post.UserId != user.Id {
+		a.forbiddenResponse(w, r)
+		return
+	}
+	if post.Status != store.PostStatusPublished {
+		a.BadRequestResponse(w, r, errors.New("only published posts can be pinned"))
+		return
+	}
+
+	if err := a.store.Pins.Pin(r.Context(), user.Id, post.Id, payload.Position); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			a.NotfoundResponse(w, r, err)
+		case store.ErrPinLimit:
+			a.conflictResponse(w, r, err)
+		default:
+			a.WriteInternalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := a.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
+
+// UnpinPost godoc
+//
+//	@Summary		Unpins a post
+//	@Description	Removes a post from the user's pinned posts
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int		true	"Post ID"
+//	@Success		204		{string}	string	"Post unpinned"
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/post/{postID}/pin [delete]
+func (a *application) unpinPostHandler(w http.ResponseWriter, r *http.Request) {
+	post := a.getPostfromCtx(r)
+	user := getUserfromCtx(r)
+
+	if err := a.store.Pins.Unpin(r.Context(), user.Id, post.Id); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			a.NotfoundResponse(w, r, err)
+		default:
+			a.WriteInternalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := a.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
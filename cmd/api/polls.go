@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/lunatictiol/go-based-social-media/internal/store"
+)
+
+type pollPayload struct {
+	Options  []string  `json:"options" validate:"min=2,max=6,dive,required,max=100"`
+	Multiple bool      `json:"multiple"`
+	ClosesAt time.Time `json:"closes_at" validate:"required"`
+}
+
+type votePayload struct {
+	OptionIds []int64 `json:"option_ids" validate:"min=1,max=6,unique"`
+}
+
+// newPoll checks the poll of a post published at publishAt, now when nil,
+// and builds it.
+func newPoll(payload *pollPayload, publishAt *time.Time) (*store.Poll, error) {
+	opens := time.Now()
+	if publishAt != nil {
+		opens = *publishAt
+	}
+	if !payload.ClosesAt.After(opens) {
+		return nil, errors.New("poll closes_at must be after the post is published")
+	}
+
+	poll := &store.Poll{
+		Multiple: payload.Multiple,
+		ClosesAt: payload.ClosesAt,
+		Options:  make([]store.PollOption, len(payload.Options)),
+	}
+	for i, text := range payload.Options {
+		poll.Options[i].Text = text
+	}
+
+	return poll, nil
+}
+
+// VotePoll godoc
+//
+//	@Summary		Votes in a poll
+//	@Description	Votes for one option of a poll, or several for multiple choice polls. Each user votes once.
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int			true	"Post ID"
+//	@Param			payload	body		votePayload	true	"Vote payload"
+//	@Success		200		{object}	store.Poll
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/post/{postID}/poll/vote [post]
+func (a *application) votePollHandler(w http.ResponseWriter, r *http.Request) {
+	var payload votePayload
+	if err := ReadJSON(w, r, &payload); err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+	if err := Validator.Struct(payload); err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+
+	post := a.getPostfromCtx(r)
+	if post.Status != store.PostStatusPublished {
+		a.BadRequestResponse(w, r, errors.New("the post isn't published yet"))
+		return
+	}
+
+	user := getUserfromCtx(r)
+	ctx := r.Context()
+
+	if err := a.store.Polls.Vote(ctx, post.Id, user.Id, payload.OptionIds); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			a.NotfoundResponse(w, r, err)
+		case store.ErrConflict:
+			a.conflictResponse(w, r, errors.New("already voted"))
+		case store.ErrPollClosed, store.ErrInvalidVote:
+			a.BadRequestResponse(w, r, err)
+		default:
+			a.WriteInternalServerError(w, r, err)
+		}
+		return
+	}
+
+	poll, err := a.store.Polls.GetByPostID(ctx, post.Id, user.Id)
+	if err != nil {
+		a.WriteInternalServerError(w, r, err)
+		return
+	}
+
+	if err := a.jsonResponse(w, http.StatusOK, poll); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
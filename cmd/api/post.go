@@ -5,9 +5,13 @@ import (
 	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi"
+	"github.com/lunatictiol/go-based-social-media/internal/entities"
+	"github.com/lunatictiol/go-based-social-media/internal/search"
 	"github.com/lunatictiol/go-based-social-media/internal/store"
+	"github.com/lunatictiol/go-based-social-media/internal/timeline"
 )
 
 type contextKey string
@@ -15,17 +19,30 @@ type contextKey string
 const postKey contextKey = "post"
 
 type postPayload struct {
-	Title   string   `json:"title" validate:"required,max=100"`
-	Content string   `json:"content" validate:"required,max=1000"`
-	Tags    []string `json:"tags"`
+	Title     string     `json:"title" validate:"required,max=100"`
+	Content   string     `json:"content" validate:"required,max=1000"`
+	Tags      []string   `json:"tags"`
+	Status    string     `json:"status" validate:"omitempty,oneof=draft scheduled published"`
+	PublishAt *time.Time `json:"publish_at"`
+	// ContentFormat is plain, the default, or markdown
+	ContentFormat string `json:"content_format" validate:"omitempty,oneof=plain markdown"`
+	// Visibility defaults to public. Mentioned-only posts are visible to
+	// the users listed in Mentions.
+	Visibility string   `json:"visibility" validate:"omitempty,oneof=public followers mentioned"`
+	Mentions   []string `json:"mentions" validate:"max=20,dive,max=100"`
+	// Poll attaches a poll to the post
+	Poll *pollPayload `json:"poll"`
 }
 
 type updatePostPayload struct {
-	Title   *string `json:"title" validate:"required,max=100"`
-	Content *string `json:"content" validate:"required,max=1000"`
+	Title         *string    `json:"title" validate:"required,max=100"`
+	Content       *string    `json:"content" validate:"required,max=1000"`
+	ContentFormat *string    `json:"content_format" validate:"omitempty,oneof=plain markdown"`
+	Status        *string    `json:"status" validate:"omitempty,oneof=draft scheduled published"`
+	PublishAt     *time.Time `json:"publish_at"`
+	Visibility    *string    `json:"visibility" validate:"omitempty,oneof=public followers mentioned"`
 }
 type commentPayload struct {
-	UserId  int    `json:"user_id" validate:"required"`
 	PostId  int    `json:"post_id" validate:"required"`
 	Content string `json:"content" validate:"required,max=1000"`
 }
@@ -56,18 +73,48 @@ func (a *application) createPosthandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if payload.Status == "" {
+		payload.Status = store.PostStatusPublished
+	}
+	if err := validatePostSchedule(payload.Status, payload.PublishAt); err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+
+	var poll *store.Poll
+	if payload.Poll != nil {
+		var err error
+		if poll, err = newPoll(payload.Poll, payload.PublishAt); err != nil {
+			a.BadRequestResponse(w, r, err)
+			return
+		}
+	}
+
+	user := getUserfromCtx(r)
+	ents := entities.Parse(payload.Content)
+
 	post := &store.Post{
-		Content: payload.Content,
-		Title:   payload.Title,
-		UserId:  1,
-		Tags:    payload.Tags,
+		Content:       payload.Content,
+		ContentFormat: payload.ContentFormat,
+		Title:         payload.Title,
+		UserId:        user.Id,
+		Tags:          entities.Merge(payload.Tags, entities.Hashtags(ents)),
+		Status:        payload.Status,
+		PublishAt:     payload.PublishAt,
+		Visibility:    payload.Visibility,
+		Mentions:      entities.Merge(payload.Mentions, entities.Mentions(ents)),
+		Entities:      ents,
+		Poll:          poll,
 	}
 
-	ctx := context.Background()
+	ctx := r.Context()
 	if err := a.store.Posts.Create(ctx, post); err != nil {
 		a.WriteInternalServerError(w, r, err)
 		return
 	}
+	a.searchEvents.Publish(search.Event{Type: search.PostChanged, ID: post.Id})
+	a.unfurler.Enqueue(post.Id)
+	a.timelines.Publish(timeline.Event{Type: timeline.PostPublished, ID: post.Id})
 
 	if err := a.jsonResponse(w, http.StatusOK, post); err != nil {
 		a.WriteInternalServerError(w, r, err)
@@ -90,13 +137,37 @@ func (a *application) createPosthandler(w http.ResponseWriter, r *http.Request)
 //	@Router			/post/{id} [get]
 func (a *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 	post := a.getPostfromCtx(r)
-	comments, err := a.store.Comments.GetByPostID(r.Context(), post.Id)
+	viewer := getUserfromCtx(r)
+
+	comments, err := a.store.Comments.GetByPostID(r.Context(), post.Id, viewer.Id)
 	if err != nil {
 		a.WriteInternalServerError(w, r, err)
 		return
 
 	}
 	post.Comments = comments
+
+	post.Bookmarked, err = a.store.Bookmarks.IsBookmarked(r.Context(), viewer.Id, sharedPostID(post))
+	if err != nil {
+		a.WriteInternalServerError(w, r, err)
+		return
+	}
+
+	post.Poll, err = a.store.Polls.GetByPostID(r.Context(), post.Id, viewer.Id)
+	if err != nil && err != store.ErrNotFound {
+		a.WriteInternalServerError(w, r, err)
+		return
+	}
+
+	if post.RepostOf != nil {
+		original, err := a.getSharedPost(r.Context(), viewer, *post.RepostOf)
+		if err != nil {
+			a.WriteInternalServerError(w, r, err)
+			return
+		}
+		post.Original = original
+	}
+
 	if err := a.jsonResponse(w, http.StatusOK, post); err != nil {
 		a.WriteInternalServerError(w, r, err)
 		return
@@ -126,7 +197,7 @@ func (a *application) deletePostHandler(w http.ResponseWriter, r *http.Request)
 	}
 	ctx := r.Context()
 
-	err = a.store.Posts.DeletePostByID(ctx, id)
+	err = a.store.Posts.DeletePostByID(ctx, id, getUserfromCtx(r).Id)
 	if err != nil {
 		switch {
 		case errors.Is(err, store.ErrNotFound):
@@ -137,6 +208,11 @@ func (a *application) deletePostHandler(w http.ResponseWriter, r *http.Request)
 		return
 
 	}
+	post := a.getPostfromCtx(r)
+	a.searchEvents.Publish(search.Event{Type: search.PostDeleted, ID: id})
+	a.timelines.Publish(timeline.Event{Type: timeline.PostRemoved, ID: id, UserID: post.UserId})
+	a.auditModeratorPostChange(r, store.AuditPostDeleted, post)
+
 	if err := a.jsonResponse(w, http.StatusOK, "post deleted successfully"); err != nil {
 		a.WriteInternalServerError(w, r, err)
 		return
@@ -147,7 +223,7 @@ func (a *application) deletePostHandler(w http.ResponseWriter, r *http.Request)
 // UpdatePost godoc
 //
 //	@Summary		Updates a post
-//	@Description	Updates a post by ID
+//	@Description	Updates a post by ID. publish_at can only be sent together with status scheduled.
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
@@ -174,17 +250,54 @@ func (a *application) updatePostHandler(w http.ResponseWriter, r *http.Request)
 	}
 
 	if payload.Content != nil {
+		// the tags and mentions that came from the old content are replaced
+		// by those in the new one, the ones given apart from it are kept
+		old := entities.Parse(post.Content)
 		post.Content = *payload.Content
+		post.Entities = entities.Parse(post.Content)
+		post.Tags = entities.Merge(entities.Without(post.Tags, entities.Hashtags(old)), entities.Hashtags(post.Entities))
+		post.Mentions = entities.Merge(entities.Without(post.Mentions, entities.Mentions(old)), entities.Mentions(post.Entities))
+	}
+	if payload.ContentFormat != nil {
+		post.ContentFormat = *payload.ContentFormat
 	}
 	if payload.Title != nil {
 		post.Title = *payload.Title
 	}
+	wasPublished := post.Status == store.PostStatusPublished
+	if payload.PublishAt != nil && payload.Status == nil {
+		a.BadRequestResponse(w, r, errors.New("publish_at needs a status, send status scheduled with it"))
+		return
+	}
+	if payload.Status != nil {
+		if wasPublished && *payload.Status != store.PostStatusPublished {
+			a.BadRequestResponse(w, r, errors.New("a published post can't be turned back into a draft or scheduled"))
+			return
+		}
+		if err := validatePostSchedule(*payload.Status, payload.PublishAt); err != nil {
+			a.BadRequestResponse(w, r, err)
+			return
+		}
+		post.Status = *payload.Status
+		post.PublishAt = payload.PublishAt
+	}
+	if payload.Visibility != nil {
+		post.Visibility = *payload.Visibility
+	}
 
 	err = a.store.Posts.UpdatePost(r.Context(), post)
 	if err != nil {
 		a.WriteInternalServerError(w, r, err)
 		return
 	}
+	a.searchEvents.Publish(search.Event{Type: search.PostChanged, ID: post.Id})
+	if payload.Content != nil {
+		a.unfurler.Enqueue(post.Id)
+	}
+	if !wasPublished && post.Status == store.PostStatusPublished {
+		a.timelines.Publish(timeline.Event{Type: timeline.PostPublished, ID: post.Id})
+	}
+	a.auditModeratorPostChange(r, store.AuditPostEdited, post)
 
 	if err := a.jsonResponse(w, http.StatusOK, post); err != nil {
 		a.WriteInternalServerError(w, r, err)
@@ -193,6 +306,22 @@ func (a *application) updatePostHandler(w http.ResponseWriter, r *http.Request)
 
 }
 
+// auditModeratorPostChange records edits and deletes of a post by someone
+// other than its author, which checkPostOwnership only lets moderators and
+// admins make.
+func (a *application) auditModeratorPostChange(r *http.Request, action string, post *store.Post) {
+	if getUserfromCtx(r).Id == post.UserId {
+		return
+	}
+
+	a.recordAudit(r, store.AuditEvent{
+		Action:     action,
+		TargetType: store.ReportTargetPost,
+		TargetId:   &post.Id,
+		Metadata:   map[string]any{"author_id": post.UserId},
+	})
+}
+
 func (a *application) postContextMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		postID := chi.URLParam(r, "postID")
@@ -212,12 +341,59 @@ func (a *application) postContextMiddleware(next http.Handler) http.Handler {
 			}
 			return
 		}
+		allowed, err := a.canViewPost(ctx, getUserfromCtx(r), post)
+		if err != nil {
+			a.WriteInternalServerError(w, r, err)
+			return
+		}
+		// don't reveal that the post exists to viewers who can't see it
+		if !allowed {
+			a.NotfoundResponse(w, r, store.ErrNotFound)
+			return
+		}
 		ctx = context.WithValue(ctx, postKey, post)
 		next.ServeHTTP(w, r.WithContext(ctx))
 
 	})
 }
 
+// canViewPost reports whether the user may see the post. Drafts and
+// scheduled posts only exist for their author, published posts follow their
+// visibility, which moderators can see past.
+func (a *application) canViewPost(ctx context.Context, user *store.User, post *store.Post) (bool, error) {
+	if post.UserId == user.Id {
+		return true, nil
+	}
+	if post.Status != store.PostStatusPublished {
+		return false, nil
+	}
+
+	visible, err := a.store.Posts.CanView(ctx, post, user.Id)
+	if err != nil || visible {
+		return visible, err
+	}
+
+	return a.checkRolePrecedence(ctx, user, "moderator")
+}
+
+// validatePostSchedule checks that publish_at is only set on scheduled posts
+// and that it points to the future.
+func validatePostSchedule(status string, publishAt *time.Time) error {
+	if status != store.PostStatusScheduled {
+		if publishAt != nil {
+			return errors.New("publish_at can only be set on scheduled posts")
+		}
+		return nil
+	}
+	if publishAt == nil {
+		return errors.New("publish_at is required for scheduled posts")
+	}
+	if !publishAt.After(time.Now()) {
+		return errors.New("publish_at must be in the future")
+	}
+	return nil
+}
+
 func (a *application) getPostfromCtx(r *http.Request) *store.Post {
 	post, _ := r.Context().Value(postKey).(*store.Post)
 	return post
@@ -234,10 +410,34 @@ func (a *application) createCommentHandler(w http.ResponseWriter, r *http.Reques
 		a.BadRequestResponse(w, r, err)
 		return
 	}
-	err = a.store.Comments.Create(r.Context(), &store.Comment{
-		UserId:  int64(payload.UserId),
-		PostId:  int64(payload.PostId),
-		Content: payload.Content,
+	ctx := r.Context()
+	user := getUserfromCtx(r)
+
+	post, err := a.store.Posts.GetPostByID(ctx, int64(payload.PostId))
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			a.NotfoundResponse(w, r, err)
+		default:
+			a.WriteInternalServerError(w, r, err)
+		}
+		return
+	}
+	allowed, err := a.canViewPost(ctx, user, post)
+	if err != nil {
+		a.WriteInternalServerError(w, r, err)
+		return
+	}
+	if !allowed {
+		a.NotfoundResponse(w, r, store.ErrNotFound)
+		return
+	}
+
+	err = a.store.Comments.Create(ctx, &store.Comment{
+		UserId:   user.Id,
+		PostId:   post.Id,
+		Content:  payload.Content,
+		Entities: entities.Parse(payload.Content),
 	})
 
 	if err != nil {
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/lunatictiol/go-based-social-media/internal/search"
+	"github.com/lunatictiol/go-based-social-media/internal/store"
+	"github.com/lunatictiol/go-based-social-media/internal/timeline"
+)
+
+type reportPayload struct {
+	TargetType string `json:"target_type" validate:"required,oneof=post comment user"`
+	TargetId   int64  `json:"target_id" validate:"required,gt=0"`
+	Reason     string `json:"reason" validate:"required,oneof=spam harassment hate violence sexual self_harm misinformation impersonation other"`
+	Details    string `json:"details" validate:"max=1000"`
+}
+
+type moderationActionPayload struct {
+	Action string `json:"action" validate:"required,oneof=dismiss hide delete warn suspend"`
+	// Note explains the action, warned users can read it and it is the
+	// reason given to suspended users
+	Note string `json:"note" validate:"max=1000"`
+	// SuspendedUntil ends a suspension, it is permanent without it
+	SuspendedUntil *time.Time `json:"suspended_until"`
+}
+
+type reportDetail struct {
+	Reports []store.Report           `json:"reports"`
+	Actions []store.ModerationAction `json:"actions"`
+}
+
+// CreateReport godoc
+//
+//	@Summary		Reports a post, comment or account
+//	@Description	Reports a post or comment you can see, or an account, to the moderators. You can have one open report per target.
+//	@Tags			moderation
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		reportPayload	true	"Report"
+//	@Success		201		{object}	store.Report
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/reports [post]
+func (a *application) createReportHandler(w http.ResponseWriter, r *http.Request) {
+	var payload reportPayload
+	if err := ReadJSON(w, r, &payload); err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+	if err := Validator.Struct(payload); err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserfromCtx(r)
+	report := &store.Report{
+		ReporterId: user.Id,
+		TargetType: payload.TargetType,
+		TargetId:   payload.TargetId,
+		Reason:     payload.Reason,
+		Details:    payload.Details,
+	}
+
+	if err := a.store.Reports.Create(r.Context(), report); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			a.NotfoundResponse(w, r, err)
+		case store.ErrSelfReport:
+			a.BadRequestResponse(w, r, err)
+		case store.ErrConflict:
+			a.conflictResponse(w, r, errors.New("you already reported this"))
+		default:
+			a.WriteInternalServerError(w, r, err)
+		}
+		return
+	}
+	report.Reporter = user.Username
+
+	if err := a.jsonResponse(w, http.StatusCreated, report); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
+
+// GetReportQueue godoc
+//
+//	@Summary		Lists the moderation queue
+//	@Description	Lists the reported targets with open reports, the most reported first, then the ones waiting the longest. Moderators only.
+//	@Tags			moderation
+//	@Produce		json
+//	@Param			target_type	query		string	false	"post, comment or user"
+//	@Param			limit		query		int		false	"Limit"
+//	@Param			offset		query		int		false	"Offset"
+//	@Success		200			{array}		store.ReportQueueItem
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/reports [get]
+func (a *application) getReportQueueHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	rq := store.ReportQueueQuery{TargetType: qs.Get("target_type"), Limit: 20}
+	if limit := qs.Get("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			a.BadRequestResponse(w, r, err)
+			return
+		}
+		rq.Limit = l
+	}
+	if offset := qs.Get("offset"); offset != "" {
+		o, err := strconv.Atoi(offset)
+		if err != nil {
+			a.BadRequestResponse(w, r, err)
+			return
+		}
+		rq.Offset = o
+	}
+	if err := Validator.Struct(rq); err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+
+	queue, err := a.store.Reports.GetQueue(r.Context(), rq)
+	if err != nil {
+		a.WriteInternalServerError(w, r, err)
+		return
+	}
+
+	if err := a.jsonResponse(w, http.StatusOK, queue); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
+
+// GetReportedTarget godoc
+//
+//	@Summary		Fetches the reports on a target
+//	@Description	Fetches every report filed on a post, comment or account and the moderation actions taken on it. Moderators only.
+//	@Tags			moderation
+//	@Produce		json
+//	@Param			targetType	path		string	true	"post, comment or user"
+//	@Param			targetID	path		int		true	"Target ID"
+//	@Success		200			{object}	reportDetail
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/reports/{targetType}/{targetID} [get]
+func (a *application) getReportedTargetHandler(w http.ResponseWriter, r *http.Request) {
+	targetType, targetID, err := reportTargetFromRequest(r)
+	if err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	reports, err := a.store.Reports.GetByTarget(ctx, targetType, targetID)
+	if err != nil {
+		a.WriteInternalServerError(w, r, err)
+		return
+	}
+	if len(reports) == 0 {
+		a.NotfoundResponse(w, r, store.ErrNotFound)
+		return
+	}
+
+	actions, err := a.store.Reports.GetActions(ctx, targetType, targetID)
+	if err != nil {
+		a.WriteInternalServerError(w, r, err)
+		return
+	}
+
+	if err := a.jsonResponse(w, http.StatusOK, reportDetail{Reports: reports, Actions: actions}); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
+
+// ResolveReports godoc
+//
+//	@Summary		Resolves the reports on a target
+//	@Description	Takes an action on a reported target and resolves all its open reports with it. Posts and comments can be hidden, leaving them visible to their author only, or deleted, any target can be dismissed, warned or have its author suspended, until suspended_until or permanently. Suspended users are emailed the note as the reason. Moderators can't suspend users with a role at or above their own. Every action is recorded. Moderators only.
+//	@Tags			moderation
+//	@Accept			json
+//	@Produce		json
+//	@Param			targetType	path		string					true	"post, comment or user"
+//	@Param			targetID	path		int						true	"Target ID"
+//	@Param			payload		body		moderationActionPayload	true	"Action"
+//	@Success		201			{object}	store.ModerationAction
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/reports/{targetType}/{targetID}/actions [post]
+func (a *application) resolveReportsHandler(w http.ResponseWriter, r *http.Request) {
+	targetType, targetID, err := reportTargetFromRequest(r)
+	if err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+
+	var payload moderationActionPayload
+	if err := ReadJSON(w, r, &payload); err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+	if err := Validator.Struct(payload); err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+
+	if targetType == store.ReportTargetUser && (payload.Action == store.ModerationHide || payload.Action == store.ModerationDelete) {
+		a.BadRequestResponse(w, r, errors.New("accounts can only be dismissed, warned or suspended"))
+		return
+	}
+	if payload.Action == store.ModerationSuspend && strings.TrimSpace(payload.Note) == "" {
+		a.BadRequestResponse(w, r, errors.New("suspensions need a note, it is the reason given to the user"))
+		return
+	}
+	if payload.SuspendedUntil != nil {
+		if payload.Action != store.ModerationSuspend {
+			a.BadRequestResponse(w, r, errors.New("suspended_until can only be set on suspensions"))
+			return
+		}
+		if !payload.SuspendedUntil.After(time.Now()) {
+			a.BadRequestResponse(w, r, errors.New("suspended_until must be in the future"))
+			return
+		}
+	}
+
+	ctx := r.Context()
+	moderator := getUserfromCtx(r)
+
+	action := &store.ModerationAction{
+		ModeratorId:    &moderator.Id,
+		TargetType:     targetType,
+		TargetId:       targetID,
+		Action:         payload.Action,
+		Note:           payload.Note,
+		SuspendedUntil: payload.SuspendedUntil,
+	}
+
+	if payload.Action == store.ModerationSuspend {
+		reports, err := a.store.Reports.GetByTarget(ctx, targetType, targetID)
+		if err != nil {
+			a.WriteInternalServerError(w, r, err)
+			return
+		}
+		if len(reports) == 0 {
+			a.NotfoundResponse(w, r, store.ErrNotFound)
+			return
+		}
+
+		allowed, err := a.canModerate(ctx, moderator, reports[0].TargetUserId)
+		if err != nil {
+			switch err {
+			case store.ErrNotFound:
+				a.NotfoundResponse(w, r, err)
+			default:
+				a.WriteInternalServerError(w, r, err)
+			}
+			return
+		}
+		if !allowed {
+			a.forbiddenResponse(w, r)
+			return
+		}
+	}
+
+	if err := a.store.Reports.Resolve(ctx, action); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			a.NotfoundResponse(w, r, err)
+		default:
+			a.WriteInternalServerError(w, r, err)
+		}
+		return
+	}
+
+	a.publishModerationAction(r, action)
+
+	a.recordAudit(r, store.AuditEvent{
+		Action:     store.AuditModeration,
+		TargetType: targetType,
+		TargetId:   &targetID,
+		Metadata: map[string]any{
+			"action":         action.Action,
+			"action_id":      action.Id,
+			"reports":        action.Reports,
+			"target_user_id": action.TargetUserId,
+		},
+	})
+	if action.Action == store.ModerationSuspend {
+		a.recordAudit(r, store.AuditEvent{
+			Action:     store.AuditSuspend,
+			TargetType: store.ReportTargetUser,
+			TargetId:   action.TargetUserId,
+			Metadata:   map[string]any{"action_id": action.Id, "ends_at": action.SuspendedUntil},
+		})
+	}
+
+	if err := a.jsonResponse(w, http.StatusCreated, action); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
+
+// publishModerationAction takes hidden and deleted posts out of timelines
+// and the search index, and takes suspended users out of the search index
+// and emails them.
+func (a *application) publishModerationAction(r *http.Request, action *store.ModerationAction) {
+	switch {
+	case action.TargetType == store.ReportTargetPost &&
+		(action.Action == store.ModerationHide || action.Action == store.ModerationDelete):
+		a.searchEvents.Publish(search.Event{Type: search.PostDeleted, ID: action.TargetId})
+		a.timelines.Publish(timeline.Event{Type: timeline.PostRemoved, ID: action.TargetId, UserID: *action.TargetUserId})
+	case action.Action == store.ModerationSuspend:
+		a.searchEvents.Publish(search.Event{Type: search.UserDeleted, ID: *action.TargetUserId})
+		a.sendSuspensionEmail(r.Context(), &store.Suspension{
+			UserId: *action.TargetUserId,
+			Reason: action.Note,
+			EndsAt: action.SuspendedUntil,
+		})
+	}
+}
+
+// GetWarnings godoc
+//
+//	@Summary		Lists the user's warnings
+//	@Description	Lists the warnings moderators gave the user, newest first
+//	@Tags			moderation
+//	@Produce		json
+//	@Success		200	{array}		store.ModerationAction
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/user/me/warnings [get]
+func (a *application) getWarningsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserfromCtx(r)
+
+	warnings, err := a.store.Reports.GetWarnings(r.Context(), user.Id)
+	if err != nil {
+		a.WriteInternalServerError(w, r, err)
+		return
+	}
+
+	if err := a.jsonResponse(w, http.StatusOK, warnings); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
+
+func reportTargetFromRequest(r *http.Request) (string, int64, error) {
+	targetType := chi.URLParam(r, "targetType")
+	switch targetType {
+	case store.ReportTargetPost, store.ReportTargetComment, store.ReportTargetUser:
+	default:
+		return "", 0, errors.New("target type must be post, comment or user")
+	}
+
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "targetID"), 10, 64)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return targetType, targetID, nil
+}
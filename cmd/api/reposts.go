@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/lunatictiol/go-based-social-media/internal/entities"
+	"github.com/lunatictiol/go-based-social-media/internal/search"
+	"github.com/lunatictiol/go-based-social-media/internal/store"
+	"github.com/lunatictiol/go-based-social-media/internal/timeline"
+)
+
+type quotePayload struct {
+	Title   string `json:"title" validate:"max=100"`
+	Content string `json:"content" validate:"required,max=1000"`
+	// ContentFormat is plain, the default, or markdown
+	ContentFormat string `json:"content_format" validate:"omitempty,oneof=plain markdown"`
+}
+
+var errShareNotPublic = errors.New("only published public posts can be shared")
+
+// sharedPostID returns the id a new repost or quote should point at. Sharing
+// a pure repost shares the post it reposted.
+func sharedPostID(post *store.Post) int64 {
+	if post.Kind == store.PostKindRepost && post.RepostOf != nil {
+		return *post.RepostOf
+	}
+	return post.Id
+}
+
+// getSharedPost loads the post a repost or quote points at. It returns nil
+// when the shared post was deleted or the user isn't allowed to see it.
+func (a *application) getSharedPost(ctx context.Context, user *store.User, postID int64) (*store.Post, error) {
+	original, err := a.store.Posts.GetPostByID(ctx, postID)
+	if err != nil {
+		if err == store.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	allowed, err := a.canViewPost(ctx, user, original)
+	if err != nil || !allowed {
+		return nil, err
+	}
+
+	return original, nil
+}
+
+// getShareablePost returns the post a new repost or quote of post points
+// at. It returns nil when post, or the original it reposts, isn't a
+// published public post the user can see.
+func (a *application) getShareablePost(ctx context.Context, user *store.User, post *store.Post) (*store.Post, error) {
+	if post.Status != store.PostStatusPublished || post.Visibility != store.PostVisibilityPublic {
+		return nil, nil
+	}
+
+	originalID := sharedPostID(post)
+	if originalID == post.Id {
+		return post, nil
+	}
+
+	original, err := a.getSharedPost(ctx, user, originalID)
+	if err != nil || original == nil {
+		return nil, err
+	}
+	if original.Status != store.PostStatusPublished || original.Visibility != store.PostVisibilityPublic {
+		return nil, nil
+	}
+
+	return original, nil
+}
+
+// Repost godoc
+//
+//	@Summary		Reposts a post
+//	@Description	Shares a post with the user's followers
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Success		201		{object}	store.Post
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/post/{postID}/repost [post]
+func (a *application) repostHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserfromCtx(r)
+
+	original, err := a.getShareablePost(r.Context(), user, a.getPostfromCtx(r))
+	if err != nil {
+		a.WriteInternalServerError(w, r, err)
+		return
+	}
+	if original == nil {
+		a.BadRequestResponse(w, r, errShareNotPublic)
+		return
+	}
+
+	post := &store.Post{
+		UserId:   user.Id,
+		Kind:     store.PostKindRepost,
+		RepostOf: &original.Id,
+	}
+
+	if err := a.store.Posts.Create(r.Context(), post); err != nil {
+		switch err {
+		case store.ErrConflict:
+			a.conflictResponse(w, r, err)
+		default:
+			a.WriteInternalServerError(w, r, err)
+		}
+		return
+	}
+	a.timelines.Publish(timeline.Event{Type: timeline.PostPublished, ID: post.Id})
+
+	if err := a.jsonResponse(w, http.StatusCreated, post); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
+
+// DeleteRepost godoc
+//
+//	@Summary		Undoes a repost
+//	@Description	Removes the user's repost of a post
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Success		200		{string}	string	"repost removed"
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/post/{postID}/repost [delete]
+func (a *application) deleteRepostHandler(w http.ResponseWriter, r *http.Request) {
+	original := a.getPostfromCtx(r)
+	user := getUserfromCtx(r)
+
+	repostID, err := a.store.Posts.DeleteRepost(r.Context(), user.Id, sharedPostID(original))
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			a.NotfoundResponse(w, r, err)
+		default:
+			a.WriteInternalServerError(w, r, err)
+		}
+		return
+	}
+	a.timelines.Publish(timeline.Event{Type: timeline.PostRemoved, ID: repostID, UserID: user.Id})
+
+	if err := a.jsonResponse(w, http.StatusOK, "repost removed successfully"); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
+
+// QuotePost godoc
+//
+//	@Summary		Quotes a post
+//	@Description	Shares a post with added commentary
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int				true	"Post ID"
+//	@Param			payload	body		quotePayload	true	"Quote payload"
+//	@Success		201		{object}	store.Post
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/post/{postID}/quote [post]
+func (a *application) quotePostHandler(w http.ResponseWriter, r *http.Request) {
+	var payload quotePayload
+	if err := ReadJSON(w, r, &payload); err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+	if err := Validator.Struct(payload); err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserfromCtx(r)
+
+	original, err := a.getShareablePost(r.Context(), user, a.getPostfromCtx(r))
+	if err != nil {
+		a.WriteInternalServerError(w, r, err)
+		return
+	}
+	if original == nil {
+		a.BadRequestResponse(w, r, errShareNotPublic)
+		return
+	}
+
+	ents := entities.Parse(payload.Content)
+
+	post := &store.Post{
+		Title:         payload.Title,
+		Content:       payload.Content,
+		ContentFormat: payload.ContentFormat,
+		UserId:        user.Id,
+		Kind:          store.PostKindQuote,
+		RepostOf:      &original.Id,
+		Tags:          entities.Hashtags(ents),
+		Mentions:      entities.Mentions(ents),
+		Entities:      ents,
+	}
+
+	if err := a.store.Posts.Create(r.Context(), post); err != nil {
+		a.WriteInternalServerError(w, r, err)
+		return
+	}
+	a.searchEvents.Publish(search.Event{Type: search.PostChanged, ID: post.Id})
+	a.unfurler.Enqueue(post.Id)
+	a.timelines.Publish(timeline.Event{Type: timeline.PostPublished, ID: post.Id})
+
+	if err := a.jsonResponse(w, http.StatusCreated, post); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
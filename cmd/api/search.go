@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/lunatictiol/go-based-social-media/internal/store"
+)
+
+// SearchPosts godoc
+//
+//	@Summary		Searches posts
+//	@Description	Full-text search over the posts visible to the user, ranked by relevance. Supports quoted phrases, OR and -excluded terms.
+//	@Tags			search
+//	@Produce		json
+//	@Param			q		query		string	true	"Search query"
+//	@Param			author	query		string	false	"Author username"
+//	@Param			tag		query		string	false	"Tag"
+//	@Param			since	query		string	false	"Created at or after (RFC3339)"
+//	@Param			until	query		string	false	"Created before (RFC3339)"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Success		200		{object}	[]store.PostSearchResult
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/search/posts [get]
+func (a *application) searchPostsHandler(w http.ResponseWriter, r *http.Request) {
+	sq := store.PostSearchQuery{
+		Limit:  20,
+		Offset: 0,
+	}
+	sq, err := sq.Parse(r)
+	if err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+	if err := Validator.Struct(sq); err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserfromCtx(r)
+
+	results, err := a.search.SearchPosts(r.Context(), user.Id, sq)
+	if err != nil {
+		a.WriteInternalServerError(w, r, err)
+		return
+	}
+
+	if err := a.jsonResponse(w, http.StatusOK, results); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
+
+type userSearchQuery struct {
+	Query string `validate:"required,max=100"`
+	Limit int    `validate:"gte=1,lte=50"`
+}
+
+// SearchUsers godoc
+//
+//	@Summary		Searches users
+//	@Description	Typo tolerant search on username and display name. Accounts you follow, or that people you follow follow, rank higher.
+//	@Tags			search
+//	@Produce		json
+//	@Param			q		query		string	true	"Search query"
+//	@Param			limit	query		int		false	"Limit"
+//	@Success		200		{object}	[]store.UserSearchResult
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/search/users [get]
+func (a *application) searchUsersHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	sq := userSearchQuery{
+		Query: strings.TrimPrefix(strings.TrimSpace(qs.Get("q")), "@"),
+		Limit: 20,
+	}
+	if limit := qs.Get("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			a.BadRequestResponse(w, r, err)
+			return
+		}
+		sq.Limit = l
+	}
+	if err := Validator.Struct(sq); err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserfromCtx(r)
+
+	users, err := a.search.SearchUsers(r.Context(), user.Id, sq.Query, sq.Limit)
+	if err != nil {
+		a.WriteInternalServerError(w, r, err)
+		return
+	}
+
+	if err := a.jsonResponse(w, http.StatusOK, users); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
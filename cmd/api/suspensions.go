@@ -0,0 +1,486 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/lunatictiol/go-based-social-media/internal/mailer"
+	"github.com/lunatictiol/go-based-social-media/internal/search"
+	"github.com/lunatictiol/go-based-social-media/internal/store"
+)
+
+// suspendedError is returned when a suspended user authenticates.
+type suspendedError struct {
+	suspension *store.Suspension
+}
+
+func (e *suspendedError) Error() string {
+	if e.suspension.EndsAt == nil {
+		return "your account is suspended permanently: " + e.suspension.Reason
+	}
+	return fmt.Sprintf("your account is suspended until %s: %s", e.suspension.EndsAt.UTC().Format(time.RFC3339), e.suspension.Reason)
+}
+
+type suspensionPayload struct {
+	Reason string `json:"reason" validate:"required,max=1000"`
+	// EndsAt ends the suspension, it is permanent without it
+	EndsAt *time.Time `json:"ends_at"`
+}
+
+type appealPayload struct {
+	Email    string `json:"email" validate:"required,email,max=255"`
+	Password string `json:"password" validate:"required,min=8,max=70"`
+	Message  string `json:"message" validate:"required,max=2000"`
+}
+
+type appealDecisionPayload struct {
+	Status   string `json:"status" validate:"required,oneof=accepted rejected"`
+	Response string `json:"response" validate:"max=2000"`
+}
+
+type appealListQuery struct {
+	Status string `validate:"oneof=pending accepted rejected"`
+	Limit  int    `validate:"gte=1,lte=50"`
+	Offset int    `validate:"gte=0"`
+}
+
+// checkSuspension returns a *suspendedError when the user has a suspension
+// in force.
+func (a *application) checkSuspension(ctx context.Context, userID int64) error {
+	suspension, err := a.store.Suspensions.GetActive(ctx, userID)
+	switch err {
+	case nil:
+		return &suspendedError{suspension: suspension}
+	case store.ErrNotFound:
+		return nil
+	default:
+		return err
+	}
+}
+
+// canModerate reports whether moderator may suspend userID, moderators
+// can't act against users whose role is at or above their own.
+func (a *application) canModerate(ctx context.Context, moderator *store.User, userID int64) (bool, error) {
+	target, err := a.store.Users.GetUserByID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	return target.Role.Level < moderator.Role.Level, nil
+}
+
+// sendSuspensionEmail tells the user about their suspension and how to
+// appeal it. Failures are only logged, the suspension stands.
+func (a *application) sendSuspensionEmail(ctx context.Context, suspension *store.Suspension) {
+	user, err := a.store.Users.GetUserByID(ctx, suspension.UserId)
+	if err != nil {
+		a.logger.Errorw("error fetching suspended user", "user", suspension.UserId, "error", err)
+		return
+	}
+
+	vars := struct {
+		Username  string
+		Reason    string
+		EndsAt    string
+		AppealURL string
+	}{
+		Username:  user.Username,
+		Reason:    suspension.Reason,
+		AppealURL: fmt.Sprintf("%s/appeal", a.config.frontendURL),
+	}
+	if suspension.EndsAt != nil {
+		vars.EndsAt = suspension.EndsAt.UTC().Format(time.RFC1123)
+	}
+
+	isProdEnv := a.config.env == "production"
+	status, err := a.mailer.Send(mailer.UserSuspendedTemplate, user.Username, user.Email, vars, !isProdEnv)
+	if err != nil {
+		a.logger.Errorw("error sending suspension email", "user", user.Id, "error", err)
+		return
+	}
+	a.logger.Infow("Email sent", "status code", status)
+}
+
+// SuspendUser godoc
+//
+//	@Summary		Suspends a user
+//	@Description	Suspends a user until ends_at, or permanently without it. Suspended users can't sign in or use their tokens and their posts and comments are hidden from everyone else. The user is emailed the reason and how to appeal. Moderators can't suspend users with a role at or above their own. Moderators only.
+//	@Tags			moderation
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path		int					true	"User ID"
+//	@Param			payload	body		suspensionPayload	true	"Suspension"
+//	@Success		201		{object}	store.Suspension
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID}/suspension [post]
+func (a *application) suspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+
+	var payload suspensionPayload
+	if err := ReadJSON(w, r, &payload); err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+	if err := Validator.Struct(payload); err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+	if payload.EndsAt != nil && !payload.EndsAt.After(time.Now()) {
+		a.BadRequestResponse(w, r, errors.New("ends_at must be in the future"))
+		return
+	}
+
+	ctx := r.Context()
+	moderator := getUserfromCtx(r)
+
+	allowed, err := a.canModerate(ctx, moderator, userID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			a.NotfoundResponse(w, r, err)
+		default:
+			a.WriteInternalServerError(w, r, err)
+		}
+		return
+	}
+	if !allowed {
+		a.forbiddenResponse(w, r)
+		return
+	}
+
+	suspension := &store.Suspension{
+		UserId:    userID,
+		Reason:    payload.Reason,
+		EndsAt:    payload.EndsAt,
+		CreatedBy: &moderator.Id,
+	}
+	if err := a.store.Suspensions.Create(ctx, suspension); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			a.NotfoundResponse(w, r, err)
+		default:
+			a.WriteInternalServerError(w, r, err)
+		}
+		return
+	}
+	a.searchEvents.Publish(search.Event{Type: search.UserDeleted, ID: userID})
+
+	a.sendSuspensionEmail(ctx, suspension)
+
+	a.recordAudit(r, store.AuditEvent{
+		Action:     store.AuditSuspend,
+		TargetType: store.ReportTargetUser,
+		TargetId:   &userID,
+		Metadata:   map[string]any{"suspension_id": suspension.Id, "ends_at": suspension.EndsAt},
+	})
+
+	if err := a.jsonResponse(w, http.StatusCreated, suspension); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
+
+// LiftSuspension godoc
+//
+//	@Summary		Lifts a user's suspension
+//	@Description	Lifts the suspensions in force of a user. Moderators can't lift the suspensions of users with a role at or above their own. Moderators only.
+//	@Tags			moderation
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"Suspension lifted"
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID}/suspension [delete]
+func (a *application) liftSuspensionHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	moderator := getUserfromCtx(r)
+
+	allowed, err := a.canModerate(ctx, moderator, userID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			a.NotfoundResponse(w, r, err)
+		default:
+			a.WriteInternalServerError(w, r, err)
+		}
+		return
+	}
+	if !allowed {
+		a.forbiddenResponse(w, r)
+		return
+	}
+
+	if err := a.store.Suspensions.Lift(ctx, userID, moderator.Id); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			a.NotfoundResponse(w, r, err)
+		default:
+			a.WriteInternalServerError(w, r, err)
+		}
+		return
+	}
+	a.searchEvents.Publish(search.Event{Type: search.UserChanged, ID: userID})
+
+	a.recordAudit(r, store.AuditEvent{Action: store.AuditLiftSuspension, TargetType: store.ReportTargetUser, TargetId: &userID})
+
+	if err := a.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
+
+// GetSuspensions godoc
+//
+//	@Summary		Lists a user's suspensions
+//	@Description	Lists every suspension of a user, newest first, including those that ended or were lifted. Moderators only.
+//	@Tags			moderation
+//	@Produce		json
+//	@Param			userID	path		int	true	"User ID"
+//	@Success		200		{array}		store.Suspension
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID}/suspensions [get]
+func (a *application) getSuspensionsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+
+	suspensions, err := a.store.Suspensions.GetByUser(r.Context(), userID)
+	if err != nil {
+		a.WriteInternalServerError(w, r, err)
+		return
+	}
+
+	if err := a.jsonResponse(w, http.StatusOK, suspensions); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
+
+// AppealSuspension godoc
+//
+//	@Summary		Appeals a suspension
+//	@Description	Appeals the suspension in force of the account, which is identified by its credentials since suspended users can't get a token. A suspension can be appealed once. Failed credentials are audited like failed logins and requests are strictly rate limited.
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		appealPayload	true	"Credentials and appeal"
+//	@Success		201		{object}	store.Appeal
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		429		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authenticate/appeal [post]
+func (a *application) appealSuspensionHandler(w http.ResponseWriter, r *http.Request) {
+	var payload appealPayload
+	if err := ReadJSON(w, r, &payload); err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+	if err := Validator.Struct(payload); err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	user, err := a.store.Users.GetByEmail(ctx, payload.Email)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			a.recordAudit(r, store.AuditEvent{Action: store.AuditLoginFailed, Metadata: map[string]any{"email": payload.Email}})
+			a.unauthorisedResponse(w, r, errInvalidCredentials)
+		default:
+			a.WriteInternalServerError(w, r, err)
+		}
+		return
+	}
+	if err := user.Password.Compare(payload.Password); err != nil {
+		a.recordAudit(r, store.AuditEvent{Action: store.AuditLoginFailed, TargetType: store.ReportTargetUser, TargetId: &user.Id, Metadata: map[string]any{"email": payload.Email}})
+		a.unauthorisedResponse(w, r, errInvalidCredentials)
+		return
+	}
+
+	appeal := &store.Appeal{Message: payload.Message}
+	if err := a.store.Suspensions.CreateAppeal(ctx, user.Id, appeal); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			a.NotfoundResponse(w, r, errors.New("your account isn't suspended"))
+		case store.ErrConflict:
+			a.conflictResponse(w, r, errors.New("the suspension was already appealed"))
+		default:
+			a.WriteInternalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := a.jsonResponse(w, http.StatusCreated, appeal); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
+
+// GetAppeals godoc
+//
+//	@Summary		Lists suspension appeals
+//	@Description	Lists the suspension appeals with a status, pending by default, oldest first. Moderators only.
+//	@Tags			moderation
+//	@Produce		json
+//	@Param			status	query		string	false	"pending, accepted or rejected"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Success		200		{array}		store.Appeal
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/appeals [get]
+func (a *application) getAppealsHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	aq := appealListQuery{Status: store.AppealPending, Limit: 20}
+	if status := qs.Get("status"); status != "" {
+		aq.Status = status
+	}
+	if limit := qs.Get("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			a.BadRequestResponse(w, r, err)
+			return
+		}
+		aq.Limit = l
+	}
+	if offset := qs.Get("offset"); offset != "" {
+		o, err := strconv.Atoi(offset)
+		if err != nil {
+			a.BadRequestResponse(w, r, err)
+			return
+		}
+		aq.Offset = o
+	}
+	if err := Validator.Struct(aq); err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+
+	appeals, err := a.store.Suspensions.GetAppeals(r.Context(), aq.Status, aq.Limit, aq.Offset)
+	if err != nil {
+		a.WriteInternalServerError(w, r, err)
+		return
+	}
+
+	if err := a.jsonResponse(w, http.StatusOK, appeals); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
+
+// DecideAppeal godoc
+//
+//	@Summary		Decides a suspension appeal
+//	@Description	Accepts or rejects a pending appeal with a response to the user. Accepting it lifts the suspension. Moderators can't decide the appeals of users with a role at or above their own. Moderators only.
+//	@Tags			moderation
+//	@Accept			json
+//	@Produce		json
+//	@Param			appealID	path		int						true	"Appeal ID"
+//	@Param			payload		body		appealDecisionPayload	true	"Decision"
+//	@Success		200			{object}	store.Appeal
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/appeals/{appealID} [put]
+func (a *application) decideAppealHandler(w http.ResponseWriter, r *http.Request) {
+	appealID, err := strconv.ParseInt(chi.URLParam(r, "appealID"), 10, 64)
+	if err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+
+	var payload appealDecisionPayload
+	if err := ReadJSON(w, r, &payload); err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+	if err := Validator.Struct(payload); err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	moderator := getUserfromCtx(r)
+
+	pending, err := a.store.Suspensions.GetAppeal(ctx, appealID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			a.NotfoundResponse(w, r, err)
+		default:
+			a.WriteInternalServerError(w, r, err)
+		}
+		return
+	}
+
+	allowed, err := a.canModerate(ctx, moderator, pending.Suspension.UserId)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			a.NotfoundResponse(w, r, err)
+		default:
+			a.WriteInternalServerError(w, r, err)
+		}
+		return
+	}
+	if !allowed {
+		a.forbiddenResponse(w, r)
+		return
+	}
+
+	appeal := &store.Appeal{Id: appealID, Status: payload.Status, Response: payload.Response}
+
+	if err := a.store.Suspensions.DecideAppeal(ctx, appeal, moderator.Id); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			a.NotfoundResponse(w, r, err)
+		default:
+			a.WriteInternalServerError(w, r, err)
+		}
+		return
+	}
+	if appeal.Status == store.AppealAccepted {
+		a.searchEvents.Publish(search.Event{Type: search.UserChanged, ID: pending.Suspension.UserId})
+	}
+
+	a.recordAudit(r, store.AuditEvent{
+		Action:     store.AuditAppealDecided,
+		TargetType: "appeal",
+		TargetId:   &appeal.Id,
+		Metadata:   map[string]any{"status": appeal.Status, "suspension_id": appeal.SuspensionId},
+	})
+
+	if err := a.jsonResponse(w, http.StatusOK, appeal); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/lunatictiol/go-based-social-media/internal/entities"
+	"github.com/lunatictiol/go-based-social-media/internal/store"
+)
+
+var errInvalidTag = errors.New("invalid tag")
+
+// trendingWindows maps the supported trending windows to the baseline
+// period their usage is compared against.
+var trendingWindows = map[string]struct {
+	window   time.Duration
+	baseline time.Duration
+}{
+	"1h":  {time.Hour, time.Hour * 24},
+	"6h":  {time.Hour * 6, time.Hour * 24 * 3},
+	"24h": {time.Hour * 24, time.Hour * 24 * 7},
+}
+
+func tagFromRequest(r *http.Request) (string, error) {
+	tag, ok := entities.NormalizeHashtag(chi.URLParam(r, "tag"))
+	if !ok {
+		return "", errInvalidTag
+	}
+	return tag, nil
+}
+
+// GetTagPosts godoc
+//
+//	@Summary		Fetches a tag timeline
+//	@Description	Fetches the public posts using a tag, newest first. It can be read without signing in, anonymous requests have their own, stricter rate limit.
+//	@Tags			tags
+//	@Produce		json
+//	@Param			tag		path		string	true	"Tag"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Success		200		{object}	[]store.PostMetaData
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		429		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/tags/{tag}/posts [get]
+func (a *application) getTagPostsHandler(w http.ResponseWriter, r *http.Request) {
+	tag, err := tagFromRequest(r)
+	if err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+
+	fq := store.PaginatedFeedQuery{
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+	}
+	fq, err = fq.Parse(r)
+	if err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+	if err := Validator.Struct(fq); err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+
+	var viewerID int64
+	if user := getUserfromCtx(r); user != nil {
+		viewerID = user.Id
+	}
+
+	posts, err := a.store.Tags.GetPosts(r.Context(), tag, viewerID, fq)
+	if err != nil {
+		a.WriteInternalServerError(w, r, err)
+		return
+	}
+
+	if err := a.jsonResponse(w, http.StatusOK, posts); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
+
+// GetTrendingTags godoc
+//
+//	@Summary		Lists trending tags
+//	@Description	Lists the tags whose usage grew the most inside the window compared to the period before it
+//	@Tags			tags
+//	@Produce		json
+//	@Param			window	query		string	false	"Trending window: 1h, 6h or 24h"
+//	@Success		200		{object}	[]store.TrendingTag
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tags/trending [get]
+func (a *application) getTrendingTagsHandler(w http.ResponseWriter, r *http.Request) {
+	windowParam := r.URL.Query().Get("window")
+	if windowParam == "" {
+		windowParam = "1h"
+	}
+	window, ok := trendingWindows[windowParam]
+	if !ok {
+		a.BadRequestResponse(w, r, errors.New("window must be one of 1h, 6h or 24h"))
+		return
+	}
+
+	tags, err := a.store.Tags.Trending(r.Context(), window.window, window.baseline, 10)
+	if err != nil {
+		a.WriteInternalServerError(w, r, err)
+		return
+	}
+
+	if err := a.jsonResponse(w, http.StatusOK, tags); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
+
+// FollowTag godoc
+//
+//	@Summary		Follows a tag
+//	@Description	Adds the public posts using a tag to the user's feed
+//	@Tags			tags
+//	@Produce		json
+//	@Param			tag	path		string	true	"Tag"
+//	@Success		204	{string}	string	"Tag followed"
+//	@Failure		400	{object}	error
+//	@Failure		409	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tags/{tag}/follow [put]
+func (a *application) followTagHandler(w http.ResponseWriter, r *http.Request) {
+	tag, err := tagFromRequest(r)
+	if err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserfromCtx(r)
+
+	if err := a.store.Tags.Follow(r.Context(), user.Id, tag); err != nil {
+		switch err {
+		case store.ErrConflict:
+			a.conflictResponse(w, r, err)
+		default:
+			a.WriteInternalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := a.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
+
+// UnfollowTag godoc
+//
+//	@Summary		Unfollows a tag
+//	@Description	Stops adding the posts using a tag to the user's feed
+//	@Tags			tags
+//	@Produce		json
+//	@Param			tag	path		string	true	"Tag"
+//	@Success		204	{string}	string	"Tag unfollowed"
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tags/{tag}/follow [delete]
+func (a *application) unfollowTagHandler(w http.ResponseWriter, r *http.Request) {
+	tag, err := tagFromRequest(r)
+	if err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserfromCtx(r)
+
+	if err := a.store.Tags.Unfollow(r.Context(), user.Id, tag); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			a.NotfoundResponse(w, r, err)
+		default:
+			a.WriteInternalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := a.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
+
+// GetFollowedTags godoc
+//
+//	@Summary		Lists followed tags
+//	@Description	Lists the tags the user follows
+//	@Tags			tags
+//	@Produce		json
+//	@Success		200	{object}	[]store.TagFollow
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/user/me/tags [get]
+func (a *application) getFollowedTagsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserfromCtx(r)
+
+	tags, err := a.store.Tags.GetFollowed(r.Context(), user.Id)
+	if err != nil {
+		a.WriteInternalServerError(w, r, err)
+		return
+	}
+
+	if err := a.jsonResponse(w, http.StatusOK, tags); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/lunatictiol/go-based-social-media/internal/store"
+)
+
+type userTimeline struct {
+	// Pinned holds the author's pinned posts in pin order. It is only
+	// filled on the first page.
+	Pinned []store.PostMetaData `json:"pinned,omitempty"`
+	Posts  []store.PostMetaData `json:"posts"`
+	// NextCursor is passed back as cursor to fetch the next page. It is
+	// empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// GetUserPosts godoc
+//
+//	@Summary		Fetches a user's posts
+//	@Description	Fetches the posts of a user that the viewer can see, newest first, with the user's pinned posts first. Followers-only and mentioned-only posts are included when the viewer follows the author or is mentioned. Posts have no replies or media attachments, so filter only separates posts from reposts.
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Param			filter	query		string	false	"all (default), posts without reposts, or reposts and quotes only"
+//	@Param			cursor	query		string	false	"Cursor returned by the previous page"
+//	@Param			limit	query		int		false	"Limit"
+//	@Success		200		{object}	userTimeline
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/user/{userID}/posts [get]
+func (a *application) getUserPostsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+
+	aq, err := authorPostsQueryFromRequest(r)
+	if err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	author, err := a.getUser(ctx, userID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			a.NotfoundResponse(w, r, err)
+		default:
+			a.WriteInternalServerError(w, r, err)
+		}
+		return
+	}
+
+	viewer := getUserfromCtx(r)
+	var timeline userTimeline
+
+	if aq.After == nil {
+		timeline.Pinned, err = a.store.Pins.GetPinned(ctx, viewer.Id, author.Id)
+		if err != nil {
+			a.WriteInternalServerError(w, r, err)
+			return
+		}
+	}
+
+	// one extra post tells whether there is a next page
+	limit := aq.Limit
+	aq.Limit++
+
+	timeline.Posts, err = a.store.Posts.GetByUser(ctx, viewer.Id, author.Id, aq)
+	if err != nil {
+		a.WriteInternalServerError(w, r, err)
+		return
+	}
+
+	if len(timeline.Posts) > limit {
+		timeline.Posts = timeline.Posts[:limit]
+		last := timeline.Posts[limit-1].Post
+		timeline.NextCursor, err = encodeCursor(store.PostCursor{CreatedAt: last.CreatedAt, Id: last.Id})
+		if err != nil {
+			a.WriteInternalServerError(w, r, err)
+			return
+		}
+	}
+
+	if err := a.jsonResponse(w, http.StatusOK, timeline); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
+
+// authorPostsQueryFromRequest reads the filter, limit and cursor query
+// parameters.
+func authorPostsQueryFromRequest(r *http.Request) (store.AuthorPostsQuery, error) {
+	qs := r.URL.Query()
+
+	aq := store.AuthorPostsQuery{
+		Limit:  20,
+		Filter: store.AuthorFilterAll,
+	}
+	if filter := qs.Get("filter"); filter != "" {
+		aq.Filter = filter
+	}
+	if limit := qs.Get("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return store.AuthorPostsQuery{}, err
+		}
+		aq.Limit = l
+	}
+	if err := Validator.Struct(aq); err != nil {
+		return store.AuthorPostsQuery{}, err
+	}
+
+	if cursor := qs.Get("cursor"); cursor != "" {
+		var after store.PostCursor
+		if err := decodeCursor(cursor, &after); err != nil {
+			return store.AuthorPostsQuery{}, err
+		}
+		if _, err := time.Parse(time.RFC3339Nano, after.CreatedAt); err != nil {
+			return store.AuthorPostsQuery{}, errInvalidCursor
+		}
+		aq.After = &after
+	}
+
+	return aq, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/lunatictiol/go-based-social-media/internal/search"
+	"github.com/lunatictiol/go-based-social-media/internal/store"
+	"github.com/lunatictiol/go-based-social-media/internal/timeline"
+)
+
+// GetTrash godoc
+//
+//	@Summary		Lists deleted posts
+//	@Description	Lists the posts the user deleted that can still be restored. Posts deleted by moderators or admins aren't listed.
+//	@Tags			posts
+//	@Produce		json
+//	@Success		200	{object}	[]store.Post
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/user/me/trash [get]
+func (a *application) getTrashHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserfromCtx(r)
+
+	posts, err := a.store.Posts.GetTrash(r.Context(), user.Id)
+	if err != nil {
+		a.WriteInternalServerError(w, r, err)
+		return
+	}
+
+	if err := a.jsonResponse(w, http.StatusOK, posts); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
+
+// RestorePost godoc
+//
+//	@Summary		Restores a deleted post
+//	@Description	Restores a post from the trash if the user deleted it less than 30 days ago. Posts deleted by moderators or admins can't be restored.
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int		true	"Post ID"
+//	@Success		200		{string}	string	"post restored"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/user/me/trash/{postID}/restore [put]
+func (a *application) restorePostHandler(w http.ResponseWriter, r *http.Request) {
+	postID, err := strconv.ParseInt(chi.URLParam(r, "postID"), 10, 64)
+	if err != nil {
+		a.BadRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserfromCtx(r)
+
+	err = a.store.Posts.Restore(r.Context(), postID, user.Id)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			a.NotfoundResponse(w, r, err)
+		default:
+			a.WriteInternalServerError(w, r, err)
+		}
+		return
+	}
+	a.searchEvents.Publish(search.Event{Type: search.PostChanged, ID: postID})
+	a.timelines.Publish(timeline.Event{Type: timeline.PostPublished, ID: postID})
+
+	if err := a.jsonResponse(w, http.StatusOK, "post restored successfully"); err != nil {
+		a.WriteInternalServerError(w, r, err)
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"strconv"
 
 	"github.com/go-chi/chi"
+	"github.com/lunatictiol/go-based-social-media/internal/search"
 	"github.com/lunatictiol/go-based-social-media/internal/store"
+	"github.com/lunatictiol/go-based-social-media/internal/timeline"
 )
 
 const userKey contextKey = "user"
@@ -86,6 +88,8 @@ func (a *application) followUserHandler(w http.ResponseWriter, r *http.Request)
 			return
 		}
 	}
+	a.timelines.Publish(timeline.Event{Type: timeline.Followed, ID: followedID, UserID: followeruser.Id})
+
 	if err := a.jsonResponse(w, http.StatusNoContent, nil); err != nil {
 		a.WriteInternalServerError(w, r, err)
 		return
@@ -115,11 +119,13 @@ func (a *application) unfollowUserHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	err = a.store.Followers.UnFollow(r.Context(), followeeuser.Id, followedID)
+	err = a.store.Followers.UnFollow(r.Context(), followedID, followeeuser.Id)
 	if err != nil {
 		a.WriteInternalServerError(w, r, err)
 		return
 	}
+	a.timelines.Publish(timeline.Event{Type: timeline.Unfollowed, ID: followedID, UserID: followeeuser.Id})
+
 	if err := a.jsonResponse(w, http.StatusNoContent, nil); err != nil {
 		a.WriteInternalServerError(w, r, err)
 		return
@@ -140,7 +146,7 @@ func (a *application) unfollowUserHandler(w http.ResponseWriter, r *http.Request
 //	@Router			/user/activate/{token} [put]
 func (a *application) activateUserHandler(w http.ResponseWriter, r *http.Request) {
 	token := chi.URLParam(r, "token")
-	err := a.store.Users.Activate(r.Context(), token)
+	user, err := a.store.Users.Activate(r.Context(), token)
 
 	if err != nil {
 		switch err {
@@ -151,6 +157,7 @@ func (a *application) activateUserHandler(w http.ResponseWriter, r *http.Request
 		}
 		return
 	}
+	a.searchEvents.Publish(search.Event{Type: search.UserChanged, ID: user.Id})
 
 	if err := a.jsonResponse(w, http.StatusNoContent, ""); err != nil {
 		a.WriteInternalServerError(w, r, err)
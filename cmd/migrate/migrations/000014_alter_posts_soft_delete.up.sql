@@ -0,0 +1,32 @@
+ALTER TABLE
+  posts
+ADD
+  COLUMN deleted_at timestamp(0) with time zone;
+
+-- who moved the post to the trash, authors can only restore the posts
+-- they deleted themselves
+ALTER TABLE
+  posts
+ADD
+  COLUMN deleted_by bigint REFERENCES users (id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_posts_deleted_at ON posts (deleted_at)
+WHERE
+  deleted_at IS NOT NULL;
+
+-- comments were never tied to posts, clear out the ones left behind by
+-- hard deletes before adding the constraint
+DELETE FROM
+  comments
+WHERE
+  post_id NOT IN (
+    SELECT
+      id
+    FROM
+      posts
+  );
+
+ALTER TABLE
+  comments
+ADD
+  CONSTRAINT fk_post FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE;
@@ -0,0 +1,26 @@
+ALTER TABLE
+  posts
+ADD
+  COLUMN status VARCHAR(20) NOT NULL DEFAULT 'published';
+
+ALTER TABLE
+  posts
+ADD
+  COLUMN publish_at timestamp(0) with time zone;
+
+ALTER TABLE
+  posts
+ADD
+  CONSTRAINT chk_posts_status CHECK (status IN ('draft', 'scheduled', 'published'));
+
+ALTER TABLE
+  posts
+ADD
+  CONSTRAINT chk_posts_publish_at CHECK (
+    status <> 'scheduled'
+    OR publish_at IS NOT NULL
+  );
+
+CREATE INDEX IF NOT EXISTS idx_posts_scheduled ON posts (publish_at)
+WHERE
+  status = 'scheduled';
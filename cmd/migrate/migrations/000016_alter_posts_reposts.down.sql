@@ -0,0 +1,12 @@
+DROP INDEX IF EXISTS idx_posts_unique_repost;
+
+DROP INDEX IF EXISTS idx_posts_repost_of;
+
+ALTER TABLE
+  posts DROP CONSTRAINT IF EXISTS chk_posts_kind;
+
+ALTER TABLE
+  posts DROP COLUMN repost_of;
+
+ALTER TABLE
+  posts DROP COLUMN kind;
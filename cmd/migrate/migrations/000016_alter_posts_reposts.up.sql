@@ -0,0 +1,35 @@
+ALTER TABLE
+  posts
+ADD
+  COLUMN kind VARCHAR(10) NOT NULL DEFAULT 'post';
+
+-- quotes are their authors' own posts, they outlive the post they quote
+-- and lose the link to it when it is purged from the trash. Pure reposts
+-- are purged with their original by the purge job.
+ALTER TABLE
+  posts
+ADD
+  COLUMN repost_of bigint REFERENCES posts (id) ON DELETE SET NULL;
+
+ALTER TABLE
+  posts
+ADD
+  CONSTRAINT chk_posts_kind CHECK (
+    (
+      kind = 'post'
+      AND repost_of IS NULL
+    )
+    OR (
+      kind = 'repost'
+      AND repost_of IS NOT NULL
+    )
+    OR kind = 'quote'
+  );
+
+CREATE INDEX IF NOT EXISTS idx_posts_repost_of ON posts (repost_of);
+
+-- a user can only share the same post once at a time
+CREATE UNIQUE INDEX IF NOT EXISTS idx_posts_unique_repost ON posts (user_id, repost_of)
+WHERE
+  kind = 'repost'
+  AND deleted_at IS NULL;
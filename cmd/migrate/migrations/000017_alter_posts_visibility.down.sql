@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS post_mentions;
+
+ALTER TABLE
+  posts DROP CONSTRAINT IF EXISTS chk_posts_visibility;
+
+ALTER TABLE
+  posts DROP COLUMN visibility;
@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS post_hashtags (
+  post_id bigint NOT NULL,
+  tag VARCHAR(100) NOT NULL,
+
+  PRIMARY KEY (post_id, tag),
+  FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_hashtags_tag ON post_hashtags (tag);
+
+CREATE TABLE IF NOT EXISTS comment_mentions (
+  comment_id bigint NOT NULL,
+  user_id bigint NOT NULL,
+
+  PRIMARY KEY (comment_id, user_id),
+  FOREIGN KEY (comment_id) REFERENCES comments (id) ON DELETE CASCADE,
+  FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_comment_mentions_user_id ON comment_mentions (user_id);
+
+CREATE TABLE IF NOT EXISTS comment_hashtags (
+  comment_id bigint NOT NULL,
+  tag VARCHAR(100) NOT NULL,
+
+  PRIMARY KEY (comment_id, tag),
+  FOREIGN KEY (comment_id) REFERENCES comments (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_comment_hashtags_tag ON comment_hashtags (tag);
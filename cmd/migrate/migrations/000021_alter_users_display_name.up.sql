@@ -0,0 +1,9 @@
+ALTER TABLE
+  users
+ADD
+  COLUMN display_name VARCHAR(100);
+
+-- pg_trgm comes from 000008_add_indexes
+CREATE INDEX IF NOT EXISTS idx_users_username_trgm ON users USING gin (username gin_trgm_ops);
+
+CREATE INDEX IF NOT EXISTS idx_users_display_name_trgm ON users USING gin (display_name gin_trgm_ops);
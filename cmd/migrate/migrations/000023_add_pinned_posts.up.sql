@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS pinned_posts (
+  user_id bigint NOT NULL,
+  post_id bigint NOT NULL,
+  position smallint NOT NULL,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+  PRIMARY KEY (user_id, post_id),
+  FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+  FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE,
+  -- a user has at most three pins, in positions 1 to 3
+  CONSTRAINT uq_pinned_posts_position UNIQUE (user_id, position),
+  CONSTRAINT chk_pinned_posts_position CHECK (position BETWEEN 1 AND 3)
+);
+
+CREATE INDEX IF NOT EXISTS idx_pinned_posts_post_id ON pinned_posts (post_id);
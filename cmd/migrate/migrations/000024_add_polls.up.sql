@@ -0,0 +1,44 @@
+CREATE TABLE IF NOT EXISTS polls (
+  post_id bigint PRIMARY KEY,
+  multiple boolean NOT NULL DEFAULT FALSE,
+  closes_at timestamp(0) with time zone NOT NULL,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+  FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS poll_options (
+  id bigserial PRIMARY KEY,
+  post_id bigint NOT NULL,
+  position smallint NOT NULL,
+  text VARCHAR(100) NOT NULL,
+
+  FOREIGN KEY (post_id) REFERENCES polls (post_id) ON DELETE CASCADE,
+  UNIQUE (post_id, position),
+  -- lets poll_votes check that the chosen option belongs to the poll
+  UNIQUE (post_id, id)
+);
+
+-- one row per user who voted, its primary key keeps users to a single vote
+CREATE TABLE IF NOT EXISTS poll_voters (
+  post_id bigint NOT NULL,
+  user_id bigint NOT NULL,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+  PRIMARY KEY (post_id, user_id),
+  FOREIGN KEY (post_id) REFERENCES polls (post_id) ON DELETE CASCADE,
+  FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+-- the options chosen in a vote, several for multiple choice polls
+CREATE TABLE IF NOT EXISTS poll_votes (
+  post_id bigint NOT NULL,
+  user_id bigint NOT NULL,
+  option_id bigint NOT NULL,
+
+  PRIMARY KEY (post_id, user_id, option_id),
+  FOREIGN KEY (post_id, user_id) REFERENCES poll_voters (post_id, user_id) ON DELETE CASCADE,
+  FOREIGN KEY (post_id, option_id) REFERENCES poll_options (post_id, id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_poll_votes_option_id ON poll_votes (option_id);
@@ -0,0 +1,21 @@
+-- link_previews caches the metadata fetched for a url, failed fetches are
+-- kept too so broken links aren't fetched over and over
+CREATE TABLE IF NOT EXISTS link_previews (
+  url text PRIMARY KEY,
+  title text NOT NULL DEFAULT '',
+  description text NOT NULL DEFAULT '',
+  image text NOT NULL DEFAULT '',
+  site_name text NOT NULL DEFAULT '',
+  type text NOT NULL DEFAULT '',
+  failed boolean NOT NULL DEFAULT FALSE,
+  fetched_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS post_links (
+  post_id bigint NOT NULL,
+  position smallint NOT NULL,
+  url text NOT NULL,
+
+  PRIMARY KEY (post_id, position),
+  FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE
+);
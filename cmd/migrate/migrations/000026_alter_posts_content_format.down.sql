@@ -0,0 +1,6 @@
+ALTER TABLE posts
+DROP CONSTRAINT IF EXISTS chk_posts_content_format;
+
+ALTER TABLE posts
+DROP COLUMN IF EXISTS content_html,
+DROP COLUMN IF EXISTS content_format;
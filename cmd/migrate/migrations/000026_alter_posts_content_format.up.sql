@@ -0,0 +1,14 @@
+ALTER TABLE posts
+ADD COLUMN content_format VARCHAR(20) NOT NULL DEFAULT 'plain',
+ADD COLUMN content_html text NOT NULL DEFAULT '';
+
+ALTER TABLE posts
+ADD CONSTRAINT chk_posts_content_format CHECK (content_format IN ('plain', 'markdown'));
+
+-- existing posts are plain text, rendered the way markdown.PlainToHTML does
+UPDATE posts
+SET content_html = '<p>' || replace(
+  replace(replace(replace(replace(replace(content, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&#34;'), '''', '&#39;'),
+  E'\n', E'<br>\n'
+) || '</p>'
+WHERE content <> '';
@@ -0,0 +1,9 @@
+-- the newest feed post a user has seen, shared by all their devices
+CREATE TABLE IF NOT EXISTS feed_markers (
+  user_id bigint PRIMARY KEY,
+  post_id bigint NOT NULL,
+  post_created_at timestamp(0) with time zone NOT NULL,
+  updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+  FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
@@ -0,0 +1,15 @@
+-- words and phrases a user doesn't want to see, pattern is the phrase
+-- compiled to a case-insensitive regular expression when it is muted
+CREATE TABLE IF NOT EXISTS muted_words (
+  id bigserial PRIMARY KEY,
+  user_id bigint NOT NULL,
+  phrase varchar(100) NOT NULL,
+  whole_word boolean NOT NULL DEFAULT true,
+  pattern text NOT NULL,
+  expires_at timestamp(0) with time zone,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+  FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_muted_words_user_phrase ON muted_words (user_id, lower(phrase));
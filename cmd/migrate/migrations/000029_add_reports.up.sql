@@ -0,0 +1,69 @@
+-- hidden posts and comments are only shown to their authors and moderators
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS hidden_at timestamp(0) with time zone;
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS hidden_at timestamp(0) with time zone;
+
+-- every action a moderator takes on a reported post, comment or account
+CREATE TABLE IF NOT EXISTS moderation_actions (
+  id bigserial PRIMARY KEY,
+  moderator_id bigint,
+  target_type varchar(10) NOT NULL,
+  target_id bigint NOT NULL,
+  target_user_id bigint,
+  action varchar(10) NOT NULL,
+  note text NOT NULL DEFAULT '',
+  reports int NOT NULL DEFAULT 0,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+  FOREIGN KEY (moderator_id) REFERENCES users (id) ON DELETE SET NULL,
+  FOREIGN KEY (target_user_id) REFERENCES users (id) ON DELETE SET NULL,
+  CHECK (target_type IN ('post', 'comment', 'user')),
+  CHECK (action IN ('dismiss', 'hide', 'delete', 'warn', 'suspend'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_moderation_actions_target ON moderation_actions (target_type, target_id);
+CREATE INDEX IF NOT EXISTS idx_moderation_actions_target_user ON moderation_actions (target_user_id, created_at);
+
+-- suspensions of the authors of reported targets, a suspension without
+-- ends_at is permanent
+CREATE TABLE IF NOT EXISTS user_suspensions (
+  id bigserial PRIMARY KEY,
+  user_id bigint NOT NULL,
+  reason text NOT NULL,
+  ends_at timestamp(0) with time zone,
+  created_by bigint,
+  action_id bigint,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  lifted_at timestamp(0) with time zone,
+  lifted_by bigint,
+
+  FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+  FOREIGN KEY (created_by) REFERENCES users (id) ON DELETE SET NULL,
+  FOREIGN KEY (lifted_by) REFERENCES users (id) ON DELETE SET NULL,
+  FOREIGN KEY (action_id) REFERENCES moderation_actions (id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_suspensions_user ON user_suspensions (user_id) WHERE lifted_at IS NULL;
+
+-- targets aren't foreign keys, reports outlive the posts, comments and
+-- accounts they are about
+CREATE TABLE IF NOT EXISTS reports (
+  id bigserial PRIMARY KEY,
+  reporter_id bigint NOT NULL,
+  target_type varchar(10) NOT NULL,
+  target_id bigint NOT NULL,
+  target_user_id bigint NOT NULL,
+  reason varchar(20) NOT NULL,
+  details text NOT NULL DEFAULT '',
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  resolved_at timestamp(0) with time zone,
+  action_id bigint,
+
+  FOREIGN KEY (reporter_id) REFERENCES users (id) ON DELETE CASCADE,
+  FOREIGN KEY (target_user_id) REFERENCES users (id) ON DELETE CASCADE,
+  FOREIGN KEY (action_id) REFERENCES moderation_actions (id) ON DELETE SET NULL,
+  CHECK (target_type IN ('post', 'comment', 'user'))
+);
+
+-- a user has one open report per target
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reports_open_reporter ON reports (reporter_id, target_type, target_id) WHERE resolved_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_reports_open_target ON reports (target_type, target_id) WHERE resolved_at IS NULL;
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS suspension_appeals (
+  id bigserial PRIMARY KEY,
+  suspension_id bigint NOT NULL UNIQUE,
+  message text NOT NULL,
+  status varchar(10) NOT NULL DEFAULT 'pending',
+  response text NOT NULL DEFAULT '',
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  decided_at timestamp(0) with time zone,
+  decided_by bigint,
+
+  FOREIGN KEY (suspension_id) REFERENCES user_suspensions (id) ON DELETE CASCADE,
+  FOREIGN KEY (decided_by) REFERENCES users (id) ON DELETE SET NULL,
+  CHECK (status IN ('pending', 'accepted', 'rejected'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_suspension_appeals_pending ON suspension_appeals (created_at) WHERE status = 'pending';
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/joho/godotenv"
+	"github.com/lunatictiol/go-based-social-media/internal/db"
+	"github.com/lunatictiol/go-based-social-media/internal/env"
+	"github.com/lunatictiol/go-based-social-media/internal/search"
+	"github.com/lunatictiol/go-based-social-media/internal/store"
+)
+
+const batchSize = 500
+
+// reindex rebuilds the external search engine's indexes from scratch.
+func main() {
+	err := godotenv.Load()
+	if err != nil {
+		log.Fatal("Error loading .env file")
+	}
+	if backend := env.GetString("SEARCH_BACKEND", "postgres"); backend != "http" {
+		log.Fatalf("nothing to reindex for the %q search backend", backend)
+	}
+
+	addr := env.GetString("DB_ADDR", "postgres")
+	dbs, err := db.New(addr, 3, 3, "5m")
+	if err != nil {
+		log.Panic(err)
+	}
+	defer dbs.Close()
+	store := store.NewStorage(dbs)
+
+	backend := search.NewHTTPBackend(
+		env.GetString("SEARCH_URL", "http://localhost:7700"),
+		env.GetString("SEARCH_APIKEY", ""),
+		store,
+	)
+
+	ctx := context.Background()
+	if err := backend.EnsureIndexes(ctx); err != nil {
+		log.Fatalf("error configuring indexes: %v", err)
+	}
+	if err := backend.Clear(ctx); err != nil {
+		log.Fatalf("error clearing indexes: %v", err)
+	}
+
+	var indexed int
+	var lastID int64
+	for {
+		users, err := store.Users.GetActive(ctx, lastID, batchSize)
+		if err != nil {
+			log.Fatalf("error loading users: %v", err)
+		}
+		if len(users) == 0 {
+			break
+		}
+		if err := backend.IndexUsers(ctx, users); err != nil {
+			log.Fatalf("error indexing users: %v", err)
+		}
+		indexed += len(users)
+		lastID = users[len(users)-1].Id
+	}
+	log.Printf("indexed %d users\n", indexed)
+
+	indexed, lastID = 0, 0
+	for {
+		posts, err := store.Posts.GetIndexable(ctx, lastID, batchSize)
+		if err != nil {
+			log.Fatalf("error loading posts: %v", err)
+		}
+		if len(posts) == 0 {
+			break
+		}
+		if err := backend.IndexPosts(ctx, posts); err != nil {
+			log.Fatalf("error indexing posts: %v", err)
+		}
+		indexed += len(posts)
+		lastID = posts[len(posts)-1].Id
+	}
+	log.Printf("indexed %d posts\n", indexed)
+
+	log.Println("reindex complete")
+}
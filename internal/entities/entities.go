@@ -0,0 +1,154 @@
+package entities
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	TypeMention = "mention"
+	TypeHashtag = "hashtag"
+
+	maxUsernameLength = 100
+	maxHashtagLength  = 100
+)
+
+// Entity is an @mention or #hashtag found in a piece of text. Start and End
+// are offsets in Unicode code points, End is exclusive, so clients can slice
+// the original text to render links.
+type Entity struct {
+	Type  string `json:"type"`
+	Text  string `json:"text"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// Parse extracts the mentions and hashtags from content in the order they
+// appear. A marker only counts at the start of the text or after a
+// character that can't be part of a word, so emails and urls are skipped.
+func Parse(content string) []Entity {
+	runes := []rune(content)
+	found := []Entity{}
+
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '@' && runes[i] != '#' {
+			continue
+		}
+		if i > 0 && (isWordRune(runes[i-1]) || runes[i-1] == '@' || runes[i-1] == '#') {
+			continue
+		}
+
+		j := i + 1
+		for j < len(runes) && isWordRune(runes[j]) {
+			j++
+		}
+		if j == i+1 {
+			continue
+		}
+
+		text := string(runes[i+1 : j])
+		switch runes[i] {
+		case '@':
+			if j-i-1 <= maxUsernameLength {
+				found = append(found, Entity{Type: TypeMention, Text: text, Start: i, End: j})
+			}
+		case '#':
+			if j-i-1 <= maxHashtagLength && hasLetter(text) {
+				found = append(found, Entity{Type: TypeHashtag, Text: text, Start: i, End: j})
+			}
+		}
+		i = j - 1
+	}
+
+	return found
+}
+
+// Mentions returns the distinct usernames mentioned in ents.
+func Mentions(ents []Entity) []string {
+	return distinct(ents, TypeMention, func(s string) string { return s })
+}
+
+// Hashtags returns the distinct hashtags in ents, lowercased.
+func Hashtags(ents []Entity) []string {
+	return distinct(ents, TypeHashtag, strings.ToLower)
+}
+
+// Merge appends the values from extra that aren't in values yet, comparing
+// case-insensitively.
+func Merge(values []string, extra []string) []string {
+	seen := make(map[string]bool, len(values))
+	merged := make([]string, 0, len(values)+len(extra))
+	for _, list := range [][]string{values, extra} {
+		for _, v := range list {
+			key := strings.ToLower(v)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			merged = append(merged, v)
+		}
+	}
+	return merged
+}
+
+// Without returns the values that aren't in drop, comparing
+// case-insensitively.
+func Without(values []string, drop []string) []string {
+	dropped := make(map[string]bool, len(drop))
+	for _, v := range drop {
+		dropped[strings.ToLower(v)] = true
+	}
+
+	kept := make([]string, 0, len(values))
+	for _, v := range values {
+		if !dropped[strings.ToLower(v)] {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
+// NormalizeHashtag lowercases tag and strips a leading '#'. It reports
+// false when the result isn't something Parse would accept as a hashtag.
+func NormalizeHashtag(tag string) (string, bool) {
+	tag = strings.ToLower(strings.TrimPrefix(tag, "#"))
+	if tag == "" || len([]rune(tag)) > maxHashtagLength || !hasLetter(tag) {
+		return "", false
+	}
+	for _, r := range tag {
+		if !isWordRune(r) {
+			return "", false
+		}
+	}
+	return tag, true
+}
+
+func distinct(ents []Entity, kind string, normalize func(string) string) []string {
+	values := []string{}
+	seen := map[string]bool{}
+	for _, e := range ents {
+		if e.Type != kind {
+			continue
+		}
+		v := normalize(e.Text)
+		if seen[v] {
+			continue
+		}
+		seen[v] = true
+		values = append(values, v)
+	}
+	return values
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func hasLetter(s string) bool {
+	for _, r := range s {
+		if unicode.IsLetter(r) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package entities
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []Entity
+	}{
+		{"none", "nothing to see", []Entity{}},
+		{"mention", "hi @ada", []Entity{{TypeMention, "ada", 3, 7}}},
+		{"hashtag", "#golang rocks", []Entity{{TypeHashtag, "golang", 0, 7}}},
+		{"in order", "@ada likes #go and @grace", []Entity{
+			{TypeMention, "ada", 0, 4},
+			{TypeHashtag, "go", 11, 14},
+			{TypeMention, "grace", 19, 25},
+		}},
+		// offsets count code points, not bytes
+		{"after multibyte text", "héllo wörld #café", []Entity{{TypeHashtag, "café", 12, 17}}},
+		{"after emoji", "🎉🎉 @ada", []Entity{{TypeMention, "ada", 3, 7}}},
+		{"non latin", "#日本語 @пользователь", []Entity{
+			{TypeHashtag, "日本語", 0, 4},
+			{TypeMention, "пользователь", 5, 18},
+		}},
+		{"punctuation ends them", "(@ada), #go!", []Entity{
+			{TypeMention, "ada", 1, 5},
+			{TypeHashtag, "go", 8, 11},
+		}},
+		{"underscores and digits", "@ada_99 #go_1", []Entity{
+			{TypeMention, "ada_99", 0, 7},
+			{TypeHashtag, "go_1", 8, 13},
+		}},
+		{"emails are skipped", "mail ada@example.com", []Entity{}},
+		{"url fragments are skipped", "https://example.com/page#section", []Entity{}},
+		{"doubled markers are skipped", "@@ada ##go #@x", []Entity{}},
+		{"bare markers", "@ # @!", []Entity{}},
+		{"numeric hashtags are skipped", "#2024 #1st", []Entity{{TypeHashtag, "1st", 6, 10}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Parse(tt.content)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Parse(%q) = %+v, want %+v", tt.content, got, tt.want)
+			}
+			runes := []rune(tt.content)
+			for _, e := range got {
+				if text := string(runes[e.Start+1 : e.End]); text != e.Text {
+					t.Errorf("content[%d:%d] = %q, want %q", e.Start+1, e.End, text, e.Text)
+				}
+			}
+		})
+	}
+}
+
+func TestParseLengthLimits(t *testing.T) {
+	long := make([]rune, maxUsernameLength+1)
+	for i := range long {
+		long[i] = 'a'
+	}
+
+	if got := Parse("@" + string(long[:maxUsernameLength])); len(got) != 1 {
+		t.Errorf("a %d character username wasn't parsed", maxUsernameLength)
+	}
+	if got := Parse("@" + string(long) + " #" + string(long)); len(got) != 0 {
+		t.Errorf("Parse() = %+v, want names over the limits skipped", got)
+	}
+}
+
+func TestMentionsAndHashtags(t *testing.T) {
+	ents := Parse("@Ada #Go @ada #go #GO @grace")
+
+	if got, want := Mentions(ents), []string{"Ada", "ada", "grace"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Mentions() = %q, want %q", got, want)
+	}
+	if got, want := Hashtags(ents), []string{"go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Hashtags() = %q, want %q", got, want)
+	}
+}
+
+func TestMergeAndWithout(t *testing.T) {
+	tests := []struct {
+		name string
+		got  []string
+		want []string
+	}{
+		{"merge keeps the first spelling", Merge([]string{"Go", "rust"}, []string{"go", "zig"}), []string{"Go", "rust", "zig"}},
+		{"merge of nothing", Merge(nil, nil), []string{}},
+		{"without is case-insensitive", Without([]string{"Go", "rust", "zig"}, []string{"GO", "zig"}), []string{"rust"}},
+		{"without nothing", Without([]string{"go"}, nil), []string{"go"}},
+	}
+
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeHashtag(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"#GoLang", "golang", true},
+		{"café", "café", true},
+		{"go_1", "go_1", true},
+		{"", "", false},
+		{"#", "", false},
+		{"2024", "", false},
+		{"two words", "", false},
+		{"#go!", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := NormalizeHashtag(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("NormalizeHashtag(%q) = %q, %v, want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
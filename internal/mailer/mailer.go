@@ -3,9 +3,10 @@ package mailer
 import "embed"
 
 const (
-	fromName            = "Go social media"
-	maxTries            = 3
-	UserWelcomeTemplate = "user_invitation.tmpl"
+	fromName              = "Go social media"
+	maxTries              = 3
+	UserWelcomeTemplate   = "user_invitation.tmpl"
+	UserSuspendedTemplate = "user_suspended.tmpl"
 )
 
 //go:embed "templates"
@@ -0,0 +1,254 @@
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// emphasis maps the delimiters to the tag they render, longest first.
+var emphasis = []struct {
+	delim string
+	tag   string
+}{
+	{"**", "strong"},
+	{"__", "strong"},
+	{"~~", "del"},
+	{"*", "em"},
+	{"_", "em"},
+}
+
+const linkRel = `rel="nofollow ugc noopener"`
+
+// renderInline renders the spans of a single line. Anything that isn't
+// recognised markup is written out escaped. inLink is set for link text,
+// which can't hold links of its own.
+func renderInline(b *strings.Builder, s string, depth int, inLink bool) {
+	for i := 0; i < len(s); {
+		c := s[i]
+
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte("\\`*_~[]()#>-+.!", s[i+1]) >= 0:
+			b.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+			continue
+
+		case c == '`':
+			if n := renderCode(b, s[i:]); n > 0 {
+				i += n
+				continue
+			}
+
+		case c == '[' && !inLink:
+			if n := renderLink(b, s[i:], depth); n > 0 {
+				i += n
+				continue
+			}
+
+		case c == 'h' && !inLink && (i == 0 || !isWordByte(s[i-1])):
+			if n := renderAutolink(b, s[i:]); n > 0 {
+				i += n
+				continue
+			}
+
+		case (c == '*' || c == '_' || c == '~') && depth < maxDepth:
+			if n := renderEmphasis(b, s, i, depth, inLink); n > 0 {
+				i += n
+				continue
+			}
+		}
+
+		_, size := utf8.DecodeRuneInString(s[i:])
+		b.WriteString(html.EscapeString(s[i : i+size]))
+		i += size
+	}
+}
+
+// renderCode renders a code span opened by the backticks s starts with and
+// returns how much of s it used, 0 when the span isn't closed.
+func renderCode(b *strings.Builder, s string) int {
+	ticks := len(s) - len(strings.TrimLeft(s, "`"))
+	closing := strings.Index(s[ticks:], s[:ticks])
+	if closing < 0 {
+		return 0
+	}
+
+	b.WriteString("<code>")
+	b.WriteString(html.EscapeString(strings.TrimSpace(s[ticks : ticks+closing])))
+	b.WriteString("</code>")
+
+	return ticks + closing + ticks
+}
+
+// renderLink renders a [text](url) link s starts with and returns how much
+// of s it used, 0 when s doesn't start with a link to an allowed url.
+func renderLink(b *strings.Builder, s string, depth int) int {
+	textEnd := matching(s, '[', ']')
+	if textEnd < 0 || textEnd+1 >= len(s) || s[textEnd+1] != '(' {
+		return 0
+	}
+	urlEnd := matching(s[textEnd+1:], '(', ')')
+	if urlEnd < 0 {
+		return 0
+	}
+	urlEnd += textEnd + 1
+
+	href, ok := safeURL(strings.TrimSpace(s[textEnd+2 : urlEnd]))
+	text := s[1:textEnd]
+	if !ok || text == "" {
+		return 0
+	}
+
+	b.WriteString(`<a href="` + html.EscapeString(href) + `" ` + linkRel + `>`)
+	renderInline(b, text, depth+1, true)
+	b.WriteString("</a>")
+
+	return urlEnd + 1
+}
+
+// renderAutolink links a bare http or https url s starts with and returns
+// its length, 0 when s doesn't start with one.
+func renderAutolink(b *strings.Builder, s string) int {
+	if !strings.HasPrefix(s, "http://") && !strings.HasPrefix(s, "https://") {
+		return 0
+	}
+
+	end := strings.IndexFunc(s, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '<' || r == '>' || r == '"' || r == '`'
+	})
+	if end < 0 {
+		end = len(s)
+	}
+	raw := strings.TrimRight(s[:end], ".,:;!?*_~")
+	for strings.HasSuffix(raw, ")") && strings.Count(raw, "(") < strings.Count(raw, ")") {
+		raw = raw[:len(raw)-1]
+	}
+
+	href, ok := safeURL(raw)
+	if !ok {
+		return 0
+	}
+
+	b.WriteString(`<a href="` + html.EscapeString(href) + `" ` + linkRel + `>`)
+	b.WriteString(html.EscapeString(raw))
+	b.WriteString("</a>")
+
+	return len(raw)
+}
+
+// renderEmphasis renders the emphasis opened at s[i] and returns how much of
+// s it used, 0 when the delimiter doesn't open a closed span.
+func renderEmphasis(b *strings.Builder, s string, i int, depth int, inLink bool) int {
+	for _, e := range emphasis {
+		if !strings.HasPrefix(s[i:], e.delim) {
+			continue
+		}
+
+		start := i + len(e.delim)
+		// the span has to hug its content: "**a**" but not "** a**"
+		if start >= len(s) || s[start] == ' ' {
+			continue
+		}
+		// underscores inside words are left alone so snake_case survives
+		if e.delim[0] == '_' && i > 0 && isWordByte(s[i-1]) {
+			continue
+		}
+
+		end := closingDelim(s, start, e.delim)
+		if end < 0 {
+			continue
+		}
+
+		b.WriteString("<" + e.tag + ">")
+		renderInline(b, s[start:end], depth+1, inLink)
+		b.WriteString("</" + e.tag + ">")
+
+		return end + len(e.delim) - i
+	}
+
+	return 0
+}
+
+// closingDelim finds the delimiter closing a span whose content starts at
+// s[start].
+func closingDelim(s string, start int, delim string) int {
+	for j := start + 1; j+len(delim) <= len(s); j++ {
+		if s[j] == '\\' {
+			j++
+			continue
+		}
+		if s[j] == '`' {
+			// code spans are opaque to emphasis
+			if n := strings.IndexByte(s[j+1:], '`'); n >= 0 {
+				j += n + 1
+			}
+			continue
+		}
+		if !strings.HasPrefix(s[j:], delim) || s[j-1] == ' ' {
+			continue
+		}
+		after := j + len(delim)
+		// a longer run of the same character belongs to another delimiter
+		if after < len(s) && s[after] == delim[0] {
+			continue
+		}
+		if delim[0] == '_' && after < len(s) && isWordByte(s[after]) {
+			continue
+		}
+		return j
+	}
+	return -1
+}
+
+// matching returns the index of the bracket closing the one s starts with,
+// -1 when it isn't closed.
+func matching(s string, open, close byte) int {
+	level := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case open:
+			level++
+		case close:
+			level--
+			if level == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// safeURL reports whether raw is an absolute http, https or mailto url and
+// returns it normalized.
+func safeURL(raw string) (string, bool) {
+	if raw == "" || strings.ContainsAny(raw, " \t\n") {
+		return "", false
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		if u.Host == "" {
+			return "", false
+		}
+	case "mailto":
+		if u.Opaque == "" {
+			return "", false
+		}
+	default:
+		return "", false
+	}
+
+	return u.String(), true
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
@@ -0,0 +1,170 @@
+// Package markdown renders post content to HTML that is safe to embed as
+// is. Markdown covers a small subset: paragraphs, headings, block quotes,
+// lists, fenced code, rules, emphasis, inline code and links. Raw HTML is
+// never passed through, every piece of text is escaped and links are only
+// kept for http, https and mailto urls.
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+const (
+	FormatPlain    = "plain"
+	FormatMarkdown = "markdown"
+)
+
+// maxDepth bounds how deep block quotes and emphasis can nest.
+const maxDepth = 8
+
+var (
+	headingPattern   = regexp.MustCompile(`^(#{1,6})[ \t]+(.*?)[ \t#]*$`)
+	rulePattern      = regexp.MustCompile(`^[ \t]*(?:(?:-[ \t]*){3,}|(?:\*[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	unorderedPattern = regexp.MustCompile(`^[ \t]*[-*+][ \t]+(.*)$`)
+	orderedPattern   = regexp.MustCompile(`^[ \t]*\d{1,9}[.)][ \t]+(.*)$`)
+	quotePattern     = regexp.MustCompile(`^[ \t]*>[ \t]?(.*)$`)
+	fencePattern     = regexp.MustCompile("^[ \t]*(```|~~~)")
+)
+
+// Render renders content written in format to HTML.
+func Render(format, content string) string {
+	if format == FormatMarkdown {
+		return ToHTML(content)
+	}
+	return PlainToHTML(content)
+}
+
+// PlainToHTML renders plain text as a single escaped paragraph keeping its
+// line breaks. Empty text renders to nothing.
+func PlainToHTML(content string) string {
+	if content == "" {
+		return ""
+	}
+	return "<p>" + strings.ReplaceAll(html.EscapeString(content), "\n", "<br>\n") + "</p>"
+}
+
+// ToHTML renders Markdown to HTML.
+func ToHTML(content string) string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	var b strings.Builder
+	renderBlocks(&b, strings.Split(content, "\n"), 0)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func renderBlocks(b *strings.Builder, lines []string, depth int) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+
+		switch {
+		case strings.TrimSpace(line) == "":
+			i++
+
+		case fencePattern.MatchString(line):
+			fence := fencePattern.FindStringSubmatch(line)[1]
+			i++
+			var code []string
+			for ; i < len(lines); i++ {
+				if strings.HasPrefix(strings.TrimSpace(lines[i]), fence) {
+					i++
+					break
+				}
+				code = append(code, lines[i])
+			}
+			b.WriteString("<pre><code>")
+			b.WriteString(html.EscapeString(strings.Join(code, "\n")))
+			b.WriteString("</code></pre>\n")
+
+		case headingPattern.MatchString(line):
+			m := headingPattern.FindStringSubmatch(line)
+			level := string(rune('0' + len(m[1])))
+			b.WriteString("<h" + level + ">")
+			renderInline(b, m[2], 0, false)
+			b.WriteString("</h" + level + ">\n")
+			i++
+
+		case rulePattern.MatchString(line):
+			b.WriteString("<hr>\n")
+			i++
+
+		case quotePattern.MatchString(line):
+			var quoted []string
+			for ; i < len(lines) && quotePattern.MatchString(lines[i]); i++ {
+				quoted = append(quoted, quotePattern.FindStringSubmatch(lines[i])[1])
+			}
+			b.WriteString("<blockquote>\n")
+			if depth < maxDepth {
+				renderBlocks(b, quoted, depth+1)
+			} else {
+				renderParagraph(b, quoted)
+			}
+			b.WriteString("</blockquote>\n")
+
+		case unorderedPattern.MatchString(line):
+			i = renderList(b, lines, i, "ul", unorderedPattern)
+
+		case orderedPattern.MatchString(line):
+			i = renderList(b, lines, i, "ol", orderedPattern)
+
+		default:
+			var paragraph []string
+			for ; i < len(lines) && strings.TrimSpace(lines[i]) != "" && (len(paragraph) == 0 || !startsBlock(lines[i])); i++ {
+				paragraph = append(paragraph, lines[i])
+			}
+			renderParagraph(b, paragraph)
+		}
+	}
+}
+
+// renderList renders the list starting at lines[i] and returns the index of
+// the line after it. Lines that aren't items continue the previous item.
+func renderList(b *strings.Builder, lines []string, i int, tag string, item *regexp.Regexp) int {
+	var items [][]string
+	for ; i < len(lines) && strings.TrimSpace(lines[i]) != ""; i++ {
+		if m := item.FindStringSubmatch(lines[i]); m != nil {
+			items = append(items, []string{m[1]})
+			continue
+		}
+		if startsBlock(lines[i]) {
+			break
+		}
+		items[len(items)-1] = append(items[len(items)-1], strings.TrimSpace(lines[i]))
+	}
+
+	b.WriteString("<" + tag + ">\n")
+	for _, it := range items {
+		b.WriteString("<li>")
+		renderLines(b, it)
+		b.WriteString("</li>\n")
+	}
+	b.WriteString("</" + tag + ">\n")
+
+	return i
+}
+
+func renderParagraph(b *strings.Builder, lines []string) {
+	b.WriteString("<p>")
+	renderLines(b, lines)
+	b.WriteString("</p>\n")
+}
+
+// renderLines renders lines as inline content, keeping line breaks as
+// posts are written expecting them.
+func renderLines(b *strings.Builder, lines []string) {
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteString("<br>\n")
+		}
+		renderInline(b, strings.TrimSpace(line), 0, false)
+	}
+}
+
+func startsBlock(line string) bool {
+	return fencePattern.MatchString(line) ||
+		headingPattern.MatchString(line) ||
+		rulePattern.MatchString(line) ||
+		quotePattern.MatchString(line) ||
+		unorderedPattern.MatchString(line) ||
+		orderedPattern.MatchString(line)
+}
@@ -0,0 +1,101 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+const rel = ` rel="nofollow ugc noopener"`
+
+func TestToHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"paragraph", "hello\nworld", "<p>hello<br>\nworld</p>"},
+		{"heading", "## Title ##", "<h2>Title</h2>"},
+		{"emphasis", "**bold** *em* ~~gone~~", "<p><strong>bold</strong> <em>em</em> <del>gone</del></p>"},
+		{"snake case", "snake_case_name", "<p>snake_case_name</p>"},
+		{"code span", "`a < b`", "<p><code>a &lt; b</code></p>"},
+		{"fenced code", "```\n<b>x</b>\n```", "<pre><code>&lt;b&gt;x&lt;/b&gt;</code></pre>"},
+		{"list", "- one\n- two", "<ul>\n<li>one</li>\n<li>two</li>\n</ul>"},
+		{"quote", "> quoted", "<blockquote>\n<p>quoted</p>\n</blockquote>"},
+		{"link", "[docs](https://example.com/a)", `<p><a href="https://example.com/a"` + rel + `>docs</a></p>`},
+		{"autolink", "see https://example.com/a.", `<p>see <a href="https://example.com/a"` + rel + `>https://example.com/a</a>.</p>`},
+		{"escaped markup", `\*not em\*`, "<p>*not em*</p>"},
+
+		// link text can't hold links of its own
+		{"url as link text", "[https://a.example](https://b.example)", `<p><a href="https://b.example"` + rel + `>https://a.example</a></p>`},
+		{"url inside link text", "[see https://a.example now](https://b.example)", `<p><a href="https://b.example"` + rel + `>see https://a.example now</a></p>`},
+		{"emphasised url in link text", "[**https://a.example**](https://b.example)", `<p><a href="https://b.example"` + rel + `><strong>https://a.example</strong></a></p>`},
+		{"link inside link text", "[a [b](https://a.example)](https://b.example)", `<p><a href="https://b.example"` + rel + `>a [b](https://a.example)</a></p>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ToHTML(tt.in); got != tt.want {
+				t.Errorf("ToHTML(%q)\n got: %s\nwant: %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToHTMLEscapesUnsafeInput(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"script tag", "<script>alert(1)</script>", "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>"},
+		{"event handler", `<img src=x onerror="alert(1)">`, "<p>&lt;img src=x onerror=&#34;alert(1)&#34;&gt;</p>"},
+		{"javascript link", "[x](javascript:alert(1))", "<p>[x](javascript:alert(1))</p>"},
+		{"mixed case scheme", "[x](JaVaScRiPt:alert(1))", "<p>[x](JaVaScRiPt:alert(1))</p>"},
+		{"data link", "[x](data:text/html;base64,PHNjcmlwdD4=)", "<p>[x](data:text/html;base64,PHNjcmlwdD4=)</p>"},
+		{"relative link", "[x](/admin)", "<p>[x](/admin)</p>"},
+		{"attribute breakout", `[x](https://a.example/"onmouseover="alert(1))`, `<p><a href="https://a.example/%22onmouseover=%22alert%281%29"` + rel + `>x</a></p>`},
+		{"autolink breakout", `https://a.example/"><script>`, `<p><a href="https://a.example/"` + rel + `>https://a.example/</a>&#34;&gt;&lt;script&gt;</p>`},
+		{"markup in link text", "[<b>x</b>](https://a.example)", `<p><a href="https://a.example"` + rel + `>&lt;b&gt;x&lt;/b&gt;</a></p>`},
+		{"markup in heading", "# <i>x</i>", "<h1>&lt;i&gt;x&lt;/i&gt;</h1>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ToHTML(tt.in)
+			if got != tt.want {
+				t.Errorf("ToHTML(%q)\n got: %s\nwant: %s", tt.in, got, tt.want)
+			}
+			if strings.Count(got, "<a ") != strings.Count(got, "</a>") {
+				t.Errorf("ToHTML(%q) = %s has unbalanced links", tt.in, got)
+			}
+		})
+	}
+}
+
+func TestToHTMLNestingIsBounded(t *testing.T) {
+	in := strings.Repeat(">", 100) + " deep " + strings.Repeat("*", 100) + "x" + strings.Repeat("*", 100)
+
+	got := ToHTML(in)
+	if n := strings.Count(got, "<blockquote>"); n > maxDepth+1 {
+		t.Errorf("rendered %d block quotes, want at most %d", n, maxDepth+1)
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		format  string
+		content string
+		want    string
+	}{
+		{FormatPlain, "**not** markdown\n<b>", "<p>**not** markdown<br>\n&lt;b&gt;</p>"},
+		{FormatPlain, "", ""},
+		{FormatMarkdown, "**bold**", "<p><strong>bold</strong></p>"},
+		{"", "*plain*", "<p>*plain*</p>"},
+	}
+
+	for _, tt := range tests {
+		if got := Render(tt.format, tt.content); got != tt.want {
+			t.Errorf("Render(%q, %q) = %q, want %q", tt.format, tt.content, got, tt.want)
+		}
+	}
+}
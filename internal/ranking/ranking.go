@@ -0,0 +1,64 @@
+// Package ranking orders the posts of the For You feed. Scores only depend
+// on the candidate and the time they're computed for, so the same
+// candidates rank the same way as of the same time.
+package ranking
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"github.com/lunatictiol/go-based-social-media/internal/store"
+)
+
+// Ranker scores feed candidates, higher scores rank first.
+type Ranker interface {
+	Score(c store.FeedCandidate, now time.Time) Score
+}
+
+type Score struct {
+	Value       float64
+	Explanation Explanation
+}
+
+// Explanation breaks a score down into what it was computed from.
+type Explanation struct {
+	Score      float64 `json:"score"`
+	Recency    float64 `json:"recency"`
+	Engagement float64 `json:"engagement"`
+	Affinity   float64 `json:"affinity"`
+	Tags       float64 `json:"tags"`
+	// Reasons describes the signals in words
+	Reasons []string `json:"reasons"`
+}
+
+type Ranked struct {
+	store.FeedCandidate
+	Score Score
+}
+
+// Rank scores candidates with r as of now and sorts them, best first. Ties
+// go to the newest post.
+func Rank(r Ranker, candidates []store.FeedCandidate, now time.Time) []Ranked {
+	ranked := make([]Ranked, len(candidates))
+	for i, c := range candidates {
+		ranked[i] = Ranked{FeedCandidate: c, Score: r.Score(c, now)}
+	}
+
+	slices.SortFunc(ranked, func(a, b Ranked) int {
+		if c := cmp.Compare(b.Score.Value, a.Score.Value); c != 0 {
+			return c
+		}
+		if c := createdAt(b.Post).Compare(createdAt(a.Post)); c != 0 {
+			return c
+		}
+		return cmp.Compare(b.Post.Id, a.Post.Id)
+	})
+
+	return ranked
+}
+
+func createdAt(p store.Post) time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, p.CreatedAt)
+	return t
+}
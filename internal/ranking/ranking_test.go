@@ -0,0 +1,100 @@
+package ranking
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/lunatictiol/go-based-social-media/internal/store"
+)
+
+// fixedRanker scores posts from a table, 0 when they aren't in it.
+type fixedRanker map[int64]float64
+
+func (r fixedRanker) Score(c store.FeedCandidate, _ time.Time) Score {
+	return Score{Value: r[c.Post.Id]}
+}
+
+func TestRank(t *testing.T) {
+	tests := []struct {
+		name       string
+		ranker     Ranker
+		candidates []store.FeedCandidate
+		want       []int64
+	}{
+		{
+			name:   "empty",
+			ranker: fixedRanker{},
+			want:   []int64{},
+		},
+		{
+			name:       "best score first",
+			ranker:     fixedRanker{1: 1, 2: 3, 3: 2},
+			candidates: []store.FeedCandidate{candidate(1, 0), candidate(2, 0), candidate(3, 0)},
+			want:       []int64{2, 3, 1},
+		},
+		{
+			name:       "ties go to the newest post",
+			ranker:     fixedRanker{},
+			candidates: []store.FeedCandidate{candidate(1, time.Hour), candidate(2, time.Hour*2), candidate(3, time.Minute)},
+			want:       []int64{3, 1, 2},
+		},
+		{
+			name:       "then to the highest id",
+			ranker:     fixedRanker{},
+			candidates: []store.FeedCandidate{candidate(4, time.Hour), candidate(9, time.Hour), candidate(7, time.Hour)},
+			want:       []int64{9, 7, 4},
+		},
+		{
+			name:       "engagement outranks a little recency",
+			ranker:     NewWeightedRanker(DefaultWeights()),
+			candidates: []store.FeedCandidate{candidate(1, time.Minute), engaged(candidate(2, time.Hour), 10)},
+			want:       []int64{2, 1},
+		},
+		{
+			name:       "but not a lot of it",
+			ranker:     NewWeightedRanker(DefaultWeights()),
+			candidates: []store.FeedCandidate{candidate(1, time.Minute), engaged(candidate(2, time.Hour*48), 10)},
+			want:       []int64{1, 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranked := Rank(tt.ranker, tt.candidates, now)
+
+			got := []int64{}
+			for _, r := range ranked {
+				got = append(got, r.Post.Id)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Rank() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRankIsStable(t *testing.T) {
+	r := NewWeightedRanker(DefaultWeights())
+	candidates := []store.FeedCandidate{
+		engaged(candidate(1, time.Hour), 3),
+		candidate(2, time.Hour*3),
+		engaged(candidate(3, time.Hour*5), 20),
+		candidate(4, time.Minute),
+	}
+
+	first := Rank(r, candidates, now)
+	slices.Reverse(candidates)
+	second := Rank(r, candidates, now)
+
+	for i := range first {
+		if first[i].Post.Id != second[i].Post.Id {
+			t.Fatalf("ranking depends on the candidates' order: %d and %d at %d", first[i].Post.Id, second[i].Post.Id, i)
+		}
+	}
+}
+
+func engaged(c store.FeedCandidate, comments int) store.FeedCandidate {
+	c.CommentCount = comments
+	return c
+}
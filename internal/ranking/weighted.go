@@ -0,0 +1,85 @@
+package ranking
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/lunatictiol/go-based-social-media/internal/store"
+)
+
+type Weights struct {
+	// HalfLife is the age at which a post's recency is halved
+	HalfLife time.Duration
+	// Comment, Repost and Bookmark weigh each kind of engagement, posts have
+	// no reactions
+	Comment  float64
+	Repost   float64
+	Bookmark float64
+	// Engagement, Affinity and Tag weigh the boosts recency is multiplied by
+	Engagement float64
+	Affinity   float64
+	Tag        float64
+}
+
+func DefaultWeights() Weights {
+	return Weights{
+		HalfLife:   time.Hour * 6,
+		Comment:    2,
+		Repost:     3,
+		Bookmark:   1,
+		Engagement: 1,
+		Affinity:   0.5,
+		Tag:        0.5,
+	}
+}
+
+// WeightedRanker decays posts with age and boosts them for their
+// engagement, the viewer's past interactions with the author and the tags
+// the viewer follows:
+//
+//	score = recency * (1 + engagement + affinity + tags)
+//
+// Engagement and affinity grow logarithmically so a viral post or a single
+// close friend can't take over the feed.
+type WeightedRanker struct {
+	w Weights
+}
+
+func NewWeightedRanker(w Weights) *WeightedRanker {
+	return &WeightedRanker{w: w}
+}
+
+func (r *WeightedRanker) Score(c store.FeedCandidate, now time.Time) Score {
+	age := max(now.Sub(createdAt(c.Post)), 0)
+
+	e := Explanation{
+		Recency:    math.Exp2(-age.Hours() / r.w.HalfLife.Hours()),
+		Engagement: r.w.Engagement * math.Log1p(r.w.Comment*float64(c.CommentCount)+r.w.Repost*float64(c.Post.RepostCount)+r.w.Bookmark*float64(c.Bookmarks)),
+		Affinity:   r.w.Affinity * math.Log1p(float64(c.Interactions)),
+		Tags:       r.w.Tag * float64(c.MatchedTags),
+	}
+	e.Score = e.Recency * (1 + e.Engagement + e.Affinity + e.Tags)
+
+	e.Reasons = []string{fmt.Sprintf("posted %s ago", age.Truncate(time.Minute))}
+	if c.CommentCount > 0 || c.Post.RepostCount > 0 || c.Bookmarks > 0 {
+		e.Reasons = append(e.Reasons, fmt.Sprintf("%d comments, %d reposts and %d bookmarks", c.CommentCount, c.Post.RepostCount, c.Bookmarks))
+	}
+	if c.Interactions > 0 {
+		e.Reasons = append(e.Reasons, fmt.Sprintf("you interacted with @%s's posts %d times", author(c.Post), c.Interactions))
+	}
+	if c.MatchedTags > 0 {
+		e.Reasons = append(e.Reasons, fmt.Sprintf("has %d of the tags you follow", c.MatchedTags))
+	}
+
+	return Score{Value: e.Score, Explanation: e}
+}
+
+// author is who wrote the content of p, the original's author for pure
+// reposts.
+func author(p store.Post) string {
+	if p.Kind == store.PostKindRepost && p.Original != nil {
+		return p.Original.User.Username
+	}
+	return p.User.Username
+}
@@ -0,0 +1,121 @@
+package ranking
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/lunatictiol/go-based-social-media/internal/store"
+)
+
+var now = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// candidate is a post created age before now.
+func candidate(id int64, age time.Duration) store.FeedCandidate {
+	var c store.FeedCandidate
+	c.Post.Id = id
+	c.Post.CreatedAt = now.Add(-age).Format(time.RFC3339Nano)
+	c.Post.User.Username = "ada"
+	return c
+}
+
+func TestWeightedRankerScore(t *testing.T) {
+	r := NewWeightedRanker(DefaultWeights())
+
+	tests := []struct {
+		name   string
+		modify func(c *store.FeedCandidate)
+		age    time.Duration
+		want   Explanation
+	}{
+		{
+			name: "fresh post",
+			want: Explanation{Score: 1, Recency: 1},
+		},
+		{
+			name: "one half life old",
+			age:  time.Hour * 6,
+			want: Explanation{Score: 0.5, Recency: 0.5},
+		},
+		{
+			name: "two half lives old",
+			age:  time.Hour * 12,
+			want: Explanation{Score: 0.25, Recency: 0.25},
+		},
+		{
+			name: "created after now counts as fresh",
+			age:  -time.Hour,
+			want: Explanation{Score: 1, Recency: 1},
+		},
+		{
+			// 2*1 + 3*1 + 1*1 = 6 weighted engagement
+			name:   "engagement",
+			modify: func(c *store.FeedCandidate) { c.CommentCount, c.Post.RepostCount, c.Bookmarks = 1, 1, 1 },
+			want:   Explanation{Score: 1 + math.Log1p(6), Recency: 1, Engagement: math.Log1p(6)},
+		},
+		{
+			name:   "affinity",
+			modify: func(c *store.FeedCandidate) { c.Interactions = 3 },
+			want:   Explanation{Score: 1 + 0.5*math.Log1p(3), Recency: 1, Affinity: 0.5 * math.Log1p(3)},
+		},
+		{
+			name:   "followed tags",
+			modify: func(c *store.FeedCandidate) { c.MatchedTags = 2 },
+			want:   Explanation{Score: 2, Recency: 1, Tags: 1},
+		},
+		{
+			name:   "boosts are decayed with the post",
+			modify: func(c *store.FeedCandidate) { c.MatchedTags = 2 },
+			age:    time.Hour * 6,
+			want:   Explanation{Score: 1, Recency: 0.5, Tags: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := candidate(1, tt.age)
+			if tt.modify != nil {
+				tt.modify(&c)
+			}
+
+			got := r.Score(c, now)
+			e := got.Explanation
+			for _, v := range []struct {
+				name      string
+				got, want float64
+			}{
+				{"score", e.Score, tt.want.Score},
+				{"recency", e.Recency, tt.want.Recency},
+				{"engagement", e.Engagement, tt.want.Engagement},
+				{"affinity", e.Affinity, tt.want.Affinity},
+				{"tags", e.Tags, tt.want.Tags},
+			} {
+				if math.Abs(v.got-v.want) > 1e-9 {
+					t.Errorf("%s = %v, want %v", v.name, v.got, v.want)
+				}
+			}
+			if got.Value != e.Score {
+				t.Errorf("Value = %v, explanation says %v", got.Value, e.Score)
+			}
+		})
+	}
+}
+
+func TestWeightedRankerReasons(t *testing.T) {
+	r := NewWeightedRanker(DefaultWeights())
+
+	c := candidate(1, time.Hour*2+time.Second*30)
+	if got := r.Score(c, now).Explanation.Reasons; len(got) != 1 || got[0] != "posted 2h0m0s ago" {
+		t.Errorf("Reasons = %q, want only the age", got)
+	}
+
+	// the affinity of a pure repost is with the original's author
+	c.Post.Kind = store.PostKindRepost
+	c.Post.Original = &store.Post{}
+	c.Post.Original.User.Username = "grace"
+	c.Interactions = 2
+	got := r.Score(c, now).Explanation.Reasons
+	if len(got) != 2 || got[1] != "you interacted with @grace's posts 2 times" {
+		t.Errorf("Reasons = %q, want the age and the original author's affinity", got)
+	}
+}
@@ -0,0 +1,119 @@
+package search
+
+import (
+	"context"
+
+	"github.com/lunatictiol/go-based-social-media/internal/store"
+	"go.uber.org/zap"
+)
+
+type EventType int
+
+const (
+	PostChanged EventType = iota
+	PostDeleted
+	UserChanged
+	UserDeleted
+)
+
+// Event tells the indexer that a post or user was created, updated or
+// deleted. Users are also deleted from the index while they are
+// deactivated or suspended. Changed events are resolved against the database when they're
+// handled so the index always gets the latest state.
+type Event struct {
+	Type EventType
+	ID   int64
+}
+
+// Dispatcher feeds events to an Indexer from a background goroutine so
+// request handlers never wait on the search engine. A nil *Dispatcher
+// drops every event, which is what the Postgres backend wants.
+type Dispatcher struct {
+	indexer Indexer
+	store   store.Storage
+	logger  *zap.SugaredLogger
+	events  chan Event
+}
+
+func NewDispatcher(indexer Indexer, s store.Storage, logger *zap.SugaredLogger, buffer int) *Dispatcher {
+	return &Dispatcher{
+		indexer: indexer,
+		store:   s,
+		logger:  logger,
+		events:  make(chan Event, buffer),
+	}
+}
+
+// Publish queues an event without blocking. Events are dropped when the
+// queue is full, a reindex brings the index back in sync.
+func (d *Dispatcher) Publish(e Event) {
+	if d == nil {
+		return
+	}
+
+	select {
+	case d.events <- e:
+	default:
+		d.logger.Warnw("search event queue full, dropping event", "type", e.Type, "id", e.ID)
+	}
+}
+
+// Run handles events until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-d.events:
+			if err := d.handle(ctx, e); err != nil {
+				d.logger.Errorw("error indexing search event", "type", e.Type, "id", e.ID, "error", err)
+			}
+		}
+	}
+}
+
+func (d *Dispatcher) handle(ctx context.Context, e Event) error {
+	switch e.Type {
+	case PostChanged:
+		post, err := d.store.Posts.GetPostByID(ctx, e.ID)
+		if err == store.ErrNotFound {
+			return d.indexer.DeletePost(ctx, e.ID)
+		}
+		if err != nil {
+			return err
+		}
+		author, err := d.store.Users.GetUserByID(ctx, post.UserId)
+		if err == store.ErrNotFound {
+			return d.indexer.DeletePost(ctx, e.ID)
+		}
+		if err != nil {
+			return err
+		}
+		post.User = *author
+		return d.indexer.IndexPost(ctx, post)
+	case PostDeleted:
+		return d.indexer.DeletePost(ctx, e.ID)
+	case UserChanged:
+		user, err := d.store.Users.GetUserByID(ctx, e.ID)
+		if err == store.ErrNotFound {
+			return d.indexer.DeleteUser(ctx, e.ID)
+		}
+		if err != nil {
+			return err
+		}
+		// a user can still be suspended after one of their suspensions
+		// is lifted
+		_, err = d.store.Suspensions.GetActive(ctx, e.ID)
+		if err == nil {
+			return d.indexer.DeleteUser(ctx, e.ID)
+		}
+		if err != store.ErrNotFound {
+			return err
+		}
+		return d.indexer.IndexUser(ctx, user)
+	case UserDeleted:
+		return d.indexer.DeleteUser(ctx, e.ID)
+	}
+
+	return nil
+}
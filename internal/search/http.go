@@ -0,0 +1,339 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lunatictiol/go-based-social-media/internal/store"
+)
+
+const (
+	postsIndex = "posts"
+	usersIndex = "users"
+
+	// highlight markers that can't appear in user content, swapped for
+	// <mark> once the rest of the snippet is escaped
+	highlightPre  = "\x00mark\x00"
+	highlightPost = "\x00/mark\x00"
+)
+
+// HTTPBackend talks to a search engine exposing the Meilisearch HTTP API.
+// Hits are hydrated from the database so visibility rules and counts stay
+// the same as with the Postgres backend.
+type HTTPBackend struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+	store   store.Storage
+}
+
+func NewHTTPBackend(baseURL, apiKey string, s store.Storage) *HTTPBackend {
+	return &HTTPBackend{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		client:  &http.Client{Timeout: time.Second * 10},
+		store:   s,
+	}
+}
+
+type postDocument struct {
+	ID        int64    `json:"id"`
+	UserID    int64    `json:"user_id"`
+	Username  string   `json:"username"`
+	Title     string   `json:"title"`
+	Content   string   `json:"content"`
+	Tags      []string `json:"tags"`
+	CreatedAt int64    `json:"created_at"`
+}
+
+type userDocument struct {
+	ID          int64  `json:"id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+}
+
+func newPostDocument(post *store.Post) (postDocument, error) {
+	createdAt, err := time.Parse(time.RFC3339Nano, post.CreatedAt)
+	if err != nil {
+		return postDocument{}, err
+	}
+
+	tags := make([]string, len(post.Tags))
+	for i, tag := range post.Tags {
+		tags[i] = strings.ToLower(tag)
+	}
+
+	return postDocument{
+		ID:        post.Id,
+		UserID:    post.UserId,
+		Username:  post.User.Username,
+		Title:     post.Title,
+		Content:   post.Content,
+		Tags:      tags,
+		CreatedAt: createdAt.Unix(),
+	}, nil
+}
+
+// EnsureIndexes configures the searchable, filterable and sortable
+// attributes of the indexes.
+func (b *HTTPBackend) EnsureIndexes(ctx context.Context) error {
+	settings := map[string]any{
+		postsIndex: map[string]any{
+			"searchableAttributes": []string{"title", "content", "tags", "username"},
+			"filterableAttributes": []string{"username", "tags", "created_at"},
+			"sortableAttributes":   []string{"created_at"},
+		},
+		usersIndex: map[string]any{
+			"searchableAttributes": []string{"username", "display_name"},
+		},
+	}
+
+	for index, s := range settings {
+		if err := b.do(ctx, http.MethodPatch, "/indexes/"+index+"/settings", s, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Clear removes every document from both indexes.
+func (b *HTTPBackend) Clear(ctx context.Context) error {
+	for _, index := range []string{postsIndex, usersIndex} {
+		if err := b.do(ctx, http.MethodDelete, "/indexes/"+index+"/documents", nil, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IndexPosts adds or replaces posts in bulk. Posts that aren't Indexable
+// are skipped.
+func (b *HTTPBackend) IndexPosts(ctx context.Context, posts []store.Post) error {
+	docs := []postDocument{}
+	for i := range posts {
+		if !Indexable(&posts[i]) {
+			continue
+		}
+		doc, err := newPostDocument(&posts[i])
+		if err != nil {
+			return err
+		}
+		docs = append(docs, doc)
+	}
+	if len(docs) == 0 {
+		return nil
+	}
+
+	return b.do(ctx, http.MethodPost, "/indexes/"+postsIndex+"/documents?primaryKey=id", docs, nil)
+}
+
+// IndexUsers adds or replaces users in bulk.
+func (b *HTTPBackend) IndexUsers(ctx context.Context, users []store.User) error {
+	if len(users) == 0 {
+		return nil
+	}
+
+	docs := make([]userDocument, len(users))
+	for i, u := range users {
+		docs[i] = userDocument{ID: u.Id, Username: u.Username, DisplayName: u.DisplayName}
+	}
+
+	return b.do(ctx, http.MethodPost, "/indexes/"+usersIndex+"/documents?primaryKey=id", docs, nil)
+}
+
+func (b *HTTPBackend) IndexPost(ctx context.Context, post *store.Post) error {
+	if !Indexable(post) {
+		return b.DeletePost(ctx, post.Id)
+	}
+	return b.IndexPosts(ctx, []store.Post{*post})
+}
+
+func (b *HTTPBackend) DeletePost(ctx context.Context, postID int64) error {
+	return b.do(ctx, http.MethodDelete, fmt.Sprintf("/indexes/%s/documents/%d", postsIndex, postID), nil, nil)
+}
+
+func (b *HTTPBackend) IndexUser(ctx context.Context, user *store.User) error {
+	return b.IndexUsers(ctx, []store.User{*user})
+}
+
+func (b *HTTPBackend) DeleteUser(ctx context.Context, userID int64) error {
+	return b.do(ctx, http.MethodDelete, fmt.Sprintf("/indexes/%s/documents/%d", usersIndex, userID), nil, nil)
+}
+
+type searchRequest struct {
+	Query                 string   `json:"q"`
+	Offset                int      `json:"offset"`
+	Limit                 int      `json:"limit"`
+	Filter                []string `json:"filter,omitempty"`
+	AttributesToHighlight []string `json:"attributesToHighlight,omitempty"`
+	AttributesToCrop      []string `json:"attributesToCrop,omitempty"`
+	HighlightPreTag       string   `json:"highlightPreTag,omitempty"`
+	HighlightPostTag      string   `json:"highlightPostTag,omitempty"`
+	ShowRankingScore      bool     `json:"showRankingScore"`
+}
+
+type searchHit struct {
+	ID           int64   `json:"id"`
+	RankingScore float64 `json:"_rankingScore"`
+	Formatted    struct {
+		Content string `json:"content"`
+	} `json:"_formatted"`
+}
+
+type searchResponse struct {
+	Hits []searchHit `json:"hits"`
+}
+
+func (b *HTTPBackend) SearchPosts(ctx context.Context, viewerID int64, sq store.PostSearchQuery) ([]store.PostSearchResult, error) {
+	req := searchRequest{
+		Query:                 sq.Query,
+		Offset:                sq.Offset,
+		Limit:                 sq.Limit,
+		AttributesToHighlight: []string{"content"},
+		AttributesToCrop:      []string{"content"},
+		HighlightPreTag:       highlightPre,
+		HighlightPostTag:      highlightPost,
+		ShowRankingScore:      true,
+	}
+	if sq.Author != "" {
+		req.Filter = append(req.Filter, "username = "+strconv.Quote(sq.Author))
+	}
+	if sq.Tag != "" {
+		req.Filter = append(req.Filter, "tags = "+strconv.Quote(strings.ToLower(sq.Tag)))
+	}
+	if sq.Since != nil {
+		req.Filter = append(req.Filter, fmt.Sprintf("created_at >= %d", sq.Since.Unix()))
+	}
+	if sq.Until != nil {
+		req.Filter = append(req.Filter, fmt.Sprintf("created_at < %d", sq.Until.Unix()))
+	}
+
+	var res searchResponse
+	if err := b.do(ctx, http.MethodPost, "/indexes/"+postsIndex+"/search", req, &res); err != nil {
+		return nil, err
+	}
+	if len(res.Hits) == 0 {
+		return []store.PostSearchResult{}, nil
+	}
+
+	ids := make([]int64, len(res.Hits))
+	for i, hit := range res.Hits {
+		ids[i] = hit.ID
+	}
+
+	posts, err := b.store.Posts.GetListByIDs(ctx, viewerID, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]store.PostMetaData, len(posts))
+	for _, p := range posts {
+		byID[p.Id] = p
+	}
+
+	// hits the viewer can't see, or that were deleted since they were
+	// indexed, are dropped
+	results := []store.PostSearchResult{}
+	for _, hit := range res.Hits {
+		post, ok := byID[hit.ID]
+		if !ok {
+			continue
+		}
+		results = append(results, store.PostSearchResult{
+			PostMetaData: post,
+			Rank:         hit.RankingScore,
+			Snippet:      highlightSnippet(hit.Formatted.Content),
+		})
+	}
+
+	return results, nil
+}
+
+func (b *HTTPBackend) SearchUsers(ctx context.Context, viewerID int64, q string, limit int) ([]store.UserSearchResult, error) {
+	req := searchRequest{
+		Query:            q,
+		Limit:            limit,
+		ShowRankingScore: true,
+	}
+
+	var res searchResponse
+	if err := b.do(ctx, http.MethodPost, "/indexes/"+usersIndex+"/search", req, &res); err != nil {
+		return nil, err
+	}
+
+	ids := []int64{}
+	scores := make(map[int64]float64, len(res.Hits))
+	for _, hit := range res.Hits {
+		if hit.ID == viewerID {
+			continue
+		}
+		ids = append(ids, hit.ID)
+		scores[hit.ID] = hit.RankingScore
+	}
+	if len(ids) == 0 {
+		return []store.UserSearchResult{}, nil
+	}
+
+	users, err := b.store.Search.GetUserResults(ctx, viewerID, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range users {
+		users[i].Score = scores[users[i].Id]
+	}
+
+	return users, nil
+}
+
+// highlightSnippet escapes a highlighted fragment and turns the highlight
+// markers into <mark> tags.
+func highlightSnippet(s string) string {
+	s = html.EscapeString(s)
+	return strings.NewReplacer(highlightPre, "<mark>", highlightPost, "</mark>").Replace(s)
+}
+
+func (b *HTTPBackend) do(ctx context.Context, method, path string, body, out any) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, b.baseURL+path, reqBody)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if b.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+b.apiKey)
+	}
+
+	res, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("search engine: %s %s returned %d: %s", method, path, res.StatusCode, msg)
+	}
+	if out == nil {
+		return nil
+	}
+
+	return json.NewDecoder(res.Body).Decode(out)
+}
@@ -0,0 +1,212 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lunatictiol/go-based-social-media/internal/store"
+)
+
+type recordedRequest struct {
+	Method string
+	Path   string
+	Auth   string
+	Body   string
+}
+
+// standIn is a local stand-in for the search engine. It records every
+// request and answers searches with hits.
+type standIn struct {
+	mu       sync.Mutex
+	requests []recordedRequest
+	hits     []searchHit
+	status   int
+}
+
+func newStandIn(t *testing.T) (*standIn, *httptest.Server) {
+	s := &standIn{status: http.StatusOK}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		s.mu.Lock()
+		s.requests = append(s.requests, recordedRequest{
+			Method: r.Method,
+			Path:   r.URL.RequestURI(),
+			Auth:   r.Header.Get("Authorization"),
+			Body:   string(body),
+		})
+		status, hits := s.status, s.hits
+		s.mu.Unlock()
+
+		w.WriteHeader(status)
+		if strings.HasSuffix(r.URL.Path, "/search") {
+			json.NewEncoder(w).Encode(searchResponse{Hits: hits})
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return s, srv
+}
+
+func (s *standIn) last(t *testing.T) recordedRequest {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) == 0 {
+		t.Fatal("no request reached the search engine")
+	}
+	return s.requests[len(s.requests)-1]
+}
+
+func TestHTTPBackendIndexPost(t *testing.T) {
+	engine, srv := newStandIn(t)
+	b := NewHTTPBackend(srv.URL+"/", "secret", store.Storage{})
+
+	post := publishedPost(42, "hello #Gophers")
+	post.CreatedAt = "2024-03-01T12:00:00Z"
+	post.Tags = []string{"Gophers"}
+	post.User.Username = "ada"
+
+	if err := b.IndexPost(context.Background(), &post); err != nil {
+		t.Fatal(err)
+	}
+
+	req := engine.last(t)
+	if req.Method != http.MethodPost || req.Path != "/indexes/posts/documents?primaryKey=id" {
+		t.Fatalf("request = %s %s, want POST /indexes/posts/documents?primaryKey=id", req.Method, req.Path)
+	}
+	if req.Auth != "Bearer secret" {
+		t.Errorf("Authorization = %q, want %q", req.Auth, "Bearer secret")
+	}
+
+	var docs []postDocument
+	if err := json.Unmarshal([]byte(req.Body), &docs); err != nil {
+		t.Fatal(err)
+	}
+	want := postDocument{
+		ID:        42,
+		Username:  "ada",
+		Content:   "hello #Gophers",
+		Tags:      []string{"gophers"},
+		CreatedAt: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC).Unix(),
+	}
+	if len(docs) != 1 || docs[0].ID != want.ID || docs[0].Username != want.Username ||
+		docs[0].Content != want.Content || docs[0].CreatedAt != want.CreatedAt ||
+		len(docs[0].Tags) != 1 || docs[0].Tags[0] != "gophers" {
+		t.Errorf("documents = %+v, want [%+v]", docs, want)
+	}
+}
+
+func TestHTTPBackendIndexPostNotIndexable(t *testing.T) {
+	engine, srv := newStandIn(t)
+	b := NewHTTPBackend(srv.URL, "", store.Storage{})
+
+	post := publishedPost(42, "hello")
+	post.Visibility = store.PostVisibilityFollowers
+
+	if err := b.IndexPost(context.Background(), &post); err != nil {
+		t.Fatal(err)
+	}
+
+	req := engine.last(t)
+	if req.Method != http.MethodDelete || req.Path != "/indexes/posts/documents/42" {
+		t.Errorf("request = %s %s, want DELETE /indexes/posts/documents/42", req.Method, req.Path)
+	}
+	if req.Auth != "" {
+		t.Errorf("Authorization = %q, want none without an api key", req.Auth)
+	}
+}
+
+func TestHTTPBackendSearchPostsFilters(t *testing.T) {
+	engine, srv := newStandIn(t)
+	b := NewHTTPBackend(srv.URL, "", store.Storage{})
+
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	results, err := b.SearchPosts(context.Background(), 1, store.PostSearchQuery{
+		Query:  "gophers",
+		Author: `ada "the" admin`,
+		Tag:    "Go",
+		Since:  &since,
+		Limit:  10,
+		Offset: 20,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("results = %+v, want none without hits", results)
+	}
+
+	req := engine.last(t)
+	if req.Path != "/indexes/posts/search" {
+		t.Fatalf("path = %s, want /indexes/posts/search", req.Path)
+	}
+	var sent searchRequest
+	if err := json.Unmarshal([]byte(req.Body), &sent); err != nil {
+		t.Fatal(err)
+	}
+	wantFilters := []string{
+		`username = "ada \"the\" admin"`,
+		`tags = "go"`,
+		"created_at >= 1704067200",
+	}
+	if strings.Join(sent.Filter, "\n") != strings.Join(wantFilters, "\n") {
+		t.Errorf("filters = %q, want %q", sent.Filter, wantFilters)
+	}
+	if sent.Query != "gophers" || sent.Limit != 10 || sent.Offset != 20 {
+		t.Errorf("request = %+v, want q=gophers limit=10 offset=20", sent)
+	}
+}
+
+func TestHTTPBackendSearchUsersSkipsViewer(t *testing.T) {
+	engine, srv := newStandIn(t)
+	engine.hits = []searchHit{{ID: 7}}
+	b := NewHTTPBackend(srv.URL, "", store.Storage{})
+
+	// the only hit is the viewer, so the database is never asked for it
+	users, err := b.SearchUsers(context.Background(), 7, "gopher", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 0 {
+		t.Errorf("users = %+v, want none", users)
+	}
+}
+
+func TestHTTPBackendErrorStatus(t *testing.T) {
+	engine, srv := newStandIn(t)
+	engine.status = http.StatusInternalServerError
+	b := NewHTTPBackend(srv.URL, "", store.Storage{})
+
+	if err := b.DeleteUser(context.Background(), 3); err == nil {
+		t.Fatal("DeleteUser() succeeded on a 500 from the search engine")
+	}
+}
+
+func TestHighlightSnippet(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "hello world", "hello world"},
+		{"highlight", "hello " + highlightPre + "world" + highlightPost, "hello <mark>world</mark>"},
+		{"escapes markup", "<script>" + highlightPre + "x" + highlightPost + "</script>", "&lt;script&gt;<mark>x</mark>&lt;/script&gt;"},
+		{"literal mark tags are escaped", "<mark>x</mark>", "&lt;mark&gt;x&lt;/mark&gt;"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := highlightSnippet(tt.in); got != tt.want {
+				t.Errorf("highlightSnippet(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
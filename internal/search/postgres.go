@@ -0,0 +1,34 @@
+package search
+
+import (
+	"context"
+
+	"github.com/lunatictiol/go-based-social-media/internal/store"
+)
+
+// PostgresBackend searches with the full-text and trigram indexes of the
+// main database. Postgres keeps posts.search_vector current on its own so
+// indexing is a no-op.
+type PostgresBackend struct {
+	store store.Storage
+}
+
+func NewPostgresBackend(s store.Storage) *PostgresBackend {
+	return &PostgresBackend{store: s}
+}
+
+func (b *PostgresBackend) IndexPost(context.Context, *store.Post) error { return nil }
+
+func (b *PostgresBackend) DeletePost(context.Context, int64) error { return nil }
+
+func (b *PostgresBackend) IndexUser(context.Context, *store.User) error { return nil }
+
+func (b *PostgresBackend) DeleteUser(context.Context, int64) error { return nil }
+
+func (b *PostgresBackend) SearchPosts(ctx context.Context, viewerID int64, sq store.PostSearchQuery) ([]store.PostSearchResult, error) {
+	return b.store.Search.SearchPosts(ctx, viewerID, sq)
+}
+
+func (b *PostgresBackend) SearchUsers(ctx context.Context, viewerID int64, q string, limit int) ([]store.UserSearchResult, error) {
+	return b.store.Search.SearchUsers(ctx, viewerID, q, limit)
+}
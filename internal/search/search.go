@@ -0,0 +1,36 @@
+package search
+
+import (
+	"context"
+
+	"github.com/lunatictiol/go-based-social-media/internal/store"
+)
+
+// Indexer keeps a search index in sync with posts and users.
+type Indexer interface {
+	IndexPost(ctx context.Context, post *store.Post) error
+	DeletePost(ctx context.Context, postID int64) error
+	IndexUser(ctx context.Context, user *store.User) error
+	DeleteUser(ctx context.Context, userID int64) error
+}
+
+// Searcher answers post and user searches on behalf of a viewer.
+type Searcher interface {
+	SearchPosts(ctx context.Context, viewerID int64, sq store.PostSearchQuery) ([]store.PostSearchResult, error)
+	SearchUsers(ctx context.Context, viewerID int64, q string, limit int) ([]store.UserSearchResult, error)
+}
+
+type Backend interface {
+	Indexer
+	Searcher
+}
+
+// Indexable reports whether a post belongs in a search index. Only
+// published public posts that moderators haven't hidden are indexed, pure
+// reposts have no content of their own.
+func Indexable(post *store.Post) bool {
+	return post.DeletedAt == nil && post.HiddenAt == nil &&
+		post.Status == store.PostStatusPublished &&
+		post.Visibility == store.PostVisibilityPublic &&
+		post.Kind != store.PostKindRepost
+}
@@ -0,0 +1,286 @@
+package search
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lunatictiol/go-based-social-media/internal/store"
+	"go.uber.org/zap"
+)
+
+// fakeIndexer is an in-memory stand-in for a search engine's indexes.
+type fakeIndexer struct {
+	mu    sync.Mutex
+	posts map[int64]store.Post
+	users map[int64]store.User
+}
+
+var (
+	_ Indexer = (*fakeIndexer)(nil)
+	_ Backend = (*HTTPBackend)(nil)
+	_ Backend = (*PostgresBackend)(nil)
+)
+
+func newFakeIndexer() *fakeIndexer {
+	return &fakeIndexer{posts: map[int64]store.Post{}, users: map[int64]store.User{}}
+}
+
+func (b *fakeIndexer) IndexPost(ctx context.Context, post *store.Post) error {
+	if !Indexable(post) {
+		return b.DeletePost(ctx, post.Id)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.posts[post.Id] = *post
+	return nil
+}
+
+func (b *fakeIndexer) DeletePost(_ context.Context, postID int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.posts, postID)
+	return nil
+}
+
+func (b *fakeIndexer) IndexUser(_ context.Context, user *store.User) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.users[user.Id] = *user
+	return nil
+}
+
+func (b *fakeIndexer) DeleteUser(_ context.Context, userID int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.users, userID)
+	return nil
+}
+
+// The fake stores answer the lookups the dispatcher makes from maps, the
+// embedded stores only fill in the rest of their interfaces.
+type fakePosts struct {
+	*store.PostStore
+	posts map[int64]store.Post
+}
+
+func (s fakePosts) GetPostByID(_ context.Context, id int64) (*store.Post, error) {
+	post, ok := s.posts[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return &post, nil
+}
+
+type fakeUsers struct {
+	*store.UserStore
+	users map[int64]store.User
+}
+
+func (s fakeUsers) GetUserByID(_ context.Context, id int64) (*store.User, error) {
+	user, ok := s.users[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return &user, nil
+}
+
+type fakeSuspensions struct {
+	*store.SuspensionStore
+	suspended map[int64]bool
+}
+
+func (s fakeSuspensions) GetActive(_ context.Context, userID int64) (*store.Suspension, error) {
+	if !s.suspended[userID] {
+		return nil, store.ErrNotFound
+	}
+	return &store.Suspension{UserId: userID}, nil
+}
+
+func publishedPost(id int64, content string) store.Post {
+	return store.Post{
+		Id:         id,
+		Content:    content,
+		Status:     store.PostStatusPublished,
+		Visibility: store.PostVisibilityPublic,
+		Kind:       store.PostKindPost,
+	}
+}
+
+func TestIndexable(t *testing.T) {
+	deletedAt := "2024-01-01T00:00:00Z"
+	hiddenAt := "2024-01-01T00:00:00Z"
+
+	tests := []struct {
+		name   string
+		modify func(p *store.Post)
+		want   bool
+	}{
+		{"published public post", func(p *store.Post) {}, true},
+		{"quote", func(p *store.Post) { p.Kind = store.PostKindQuote }, true},
+		{"pure repost", func(p *store.Post) { p.Kind = store.PostKindRepost }, false},
+		{"draft", func(p *store.Post) { p.Status = store.PostStatusDraft }, false},
+		{"followers only", func(p *store.Post) { p.Visibility = store.PostVisibilityFollowers }, false},
+		{"mentioned only", func(p *store.Post) { p.Visibility = store.PostVisibilityMentioned }, false},
+		{"in the trash", func(p *store.Post) { p.DeletedAt = &deletedAt }, false},
+		{"hidden by a moderator", func(p *store.Post) { p.HiddenAt = &hiddenAt }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := publishedPost(1, "hello")
+			tt.modify(&p)
+			if got := Indexable(&p); got != tt.want {
+				t.Errorf("Indexable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestDispatcherUserEvents replays the user events the handlers publish.
+// The database holds the users' state after the change.
+func TestDispatcherUserEvents(t *testing.T) {
+	gopher := store.User{Id: 7, Username: "gopher"}
+
+	tests := []struct {
+		name      string
+		event     EventType
+		active    bool
+		suspended bool
+		indexed   bool
+	}{
+		{"activated", UserChanged, true, false, true},
+		{"reactivated by an admin", UserChanged, true, false, true},
+		{"deactivated by an admin", UserDeleted, false, false, false},
+		{"suspended", UserDeleted, true, true, false},
+		{"suspension lifted", UserChanged, true, false, true},
+		{"appeal accepted with another suspension in force", UserChanged, true, true, false},
+		{"changed after being deactivated", UserChanged, false, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			idx := newFakeIndexer()
+			if !tt.indexed {
+				// the user was indexed before the change
+				if err := idx.IndexUser(ctx, &gopher); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			users := map[int64]store.User{}
+			if tt.active {
+				users[gopher.Id] = gopher
+			}
+			d := NewDispatcher(idx, store.Storage{
+				Users:       fakeUsers{users: users},
+				Suspensions: fakeSuspensions{suspended: map[int64]bool{gopher.Id: tt.suspended}},
+			}, zap.NewNop().Sugar(), 10)
+
+			if err := d.handle(ctx, Event{Type: tt.event, ID: gopher.Id}); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := idx.users[gopher.Id]; ok != tt.indexed {
+				t.Errorf("user indexed = %v, want %v", ok, tt.indexed)
+			}
+		})
+	}
+}
+
+// TestDispatcherPostEvents replays the post events the handlers publish.
+// The database holds the post's state after the change, stored is false
+// when it no longer returns the post.
+func TestDispatcherPostEvents(t *testing.T) {
+	author := store.User{Id: 7, Username: "gopher"}
+
+	tests := []struct {
+		name    string
+		event   EventType
+		modify  func(p *store.Post)
+		stored  bool
+		indexed bool
+	}{
+		{"published by the scheduler", PostChanged, func(p *store.Post) {}, true, true},
+		{"quote created", PostChanged, func(p *store.Post) { p.Kind = store.PostKindQuote }, true, true},
+		{"edited to followers only", PostChanged, func(p *store.Post) { p.Visibility = store.PostVisibilityFollowers }, true, false},
+		{"restored from the trash", PostChanged, func(p *store.Post) {}, true, true},
+		{"moved to the trash", PostDeleted, nil, false, false},
+		{"hidden by a moderator", PostDeleted, nil, true, false},
+		{"changed after being moved to the trash", PostChanged, nil, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			idx := newFakeIndexer()
+			post := publishedPost(1, "hello")
+			post.UserId = author.Id
+			if !tt.indexed {
+				// the post was indexed before the change
+				if err := idx.IndexPost(ctx, &post); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			posts := map[int64]store.Post{}
+			if tt.stored {
+				if tt.modify != nil {
+					tt.modify(&post)
+				}
+				posts[post.Id] = post
+			}
+			d := NewDispatcher(idx, store.Storage{
+				Posts: fakePosts{posts: posts},
+				Users: fakeUsers{users: map[int64]store.User{author.Id: author}},
+			}, zap.NewNop().Sugar(), 10)
+
+			if err := d.handle(ctx, Event{Type: tt.event, ID: post.Id}); err != nil {
+				t.Fatal(err)
+			}
+			indexed, ok := idx.posts[post.Id]
+			if ok != tt.indexed {
+				t.Fatalf("post indexed = %v, want %v", ok, tt.indexed)
+			}
+			if ok && indexed.User.Username != author.Username {
+				t.Errorf("indexed author = %q, want %q", indexed.User.Username, author.Username)
+			}
+		})
+	}
+}
+
+func TestDispatcherRun(t *testing.T) {
+	idx := newFakeIndexer()
+	ctx := context.Background()
+	if err := idx.IndexUser(ctx, &store.User{Id: 7, Username: "gopher"}); err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDispatcher(idx, store.Storage{}, zap.NewNop().Sugar(), 10)
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go d.Run(runCtx)
+
+	d.Publish(Event{Type: UserDeleted, ID: 7})
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		idx.mu.Lock()
+		empty := len(idx.users) == 0
+		idx.mu.Unlock()
+		if empty {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("dispatcher didn't delete the user")
+		}
+		time.Sleep(time.Millisecond * 5)
+	}
+}
+
+func TestNilDispatcherDropsEvents(t *testing.T) {
+	var d *Dispatcher
+	d.Publish(Event{Type: PostChanged, ID: 1})
+}
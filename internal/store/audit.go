@@ -0,0 +1,202 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Audit actions. Each names what happened to the target, the actor is who
+// did it.
+const (
+	AuditLogin          = "auth.login"
+	AuditLoginFailed    = "auth.login_failed"
+	AuditLoginSuspended = "auth.login_suspended"
+	AuditPostEdited     = "post.moderator_edit"
+	AuditPostDeleted    = "post.moderator_delete"
+	AuditModeration     = "report.resolve"
+	AuditSuspend        = "user.suspend"
+	AuditLiftSuspension = "user.lift_suspension"
+	AuditAppealDecided  = "user.appeal_decide"
+	AuditRoleChanged    = "user.role_change"
+	AuditActivated      = "user.activate"
+	AuditDeactivated    = "user.deactivate"
+	AuditTokensRevoked  = "user.logout"
+)
+
+// AuditEvent records an administrative or security event. Events are
+// append-only, the database rejects updates and deletes.
+type AuditEvent struct {
+	Id         int64  `json:"id"`
+	Action     string `json:"action"`
+	ActorId    *int64 `json:"actor_id"`
+	TargetType string `json:"target_type"`
+	TargetId   *int64 `json:"target_id"`
+	IP         string `json:"ip"`
+	// RequestId matches the request id in the access log
+	RequestId string         `json:"request_id"`
+	Metadata  map[string]any `json:"metadata"`
+	CreatedAt string         `json:"created_at"`
+}
+
+type AuditQuery struct {
+	Action     string `validate:"max=50"`
+	ActorId    *int64
+	TargetType string `validate:"max=20"`
+	TargetId   *int64
+	Since      *time.Time
+	Until      *time.Time
+	// Before lists the events older than the event with this id
+	Before int64 `validate:"gte=0"`
+	Limit  int   `validate:"gte=1,lte=100"`
+}
+
+func (aq AuditQuery) Parse(r *http.Request) (AuditQuery, error) {
+	qs := r.URL.Query()
+
+	aq.Action = qs.Get("action")
+	aq.TargetType = qs.Get("target_type")
+
+	actor := qs.Get("actor_id")
+	if actor != "" {
+		id, err := strconv.ParseInt(actor, 10, 64)
+		if err != nil {
+			return AuditQuery{}, err
+		}
+		aq.ActorId = &id
+	}
+	target := qs.Get("target_id")
+	if target != "" {
+		id, err := strconv.ParseInt(target, 10, 64)
+		if err != nil {
+			return AuditQuery{}, err
+		}
+		aq.TargetId = &id
+	}
+	since := qs.Get("since")
+	if since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return AuditQuery{}, err
+		}
+		aq.Since = &t
+	}
+	until := qs.Get("until")
+	if until != "" {
+		t, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			return AuditQuery{}, err
+		}
+		aq.Until = &t
+	}
+	before := qs.Get("before")
+	if before != "" {
+		b, err := strconv.ParseInt(before, 10, 64)
+		if err != nil {
+			return AuditQuery{}, err
+		}
+		aq.Before = b
+	}
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return AuditQuery{}, err
+		}
+		aq.Limit = l
+	}
+
+	return aq, nil
+}
+
+type AuditStore struct {
+	db *sql.DB
+}
+
+func (s *AuditStore) Create(ctx context.Context, e *AuditEvent) error {
+	query := `
+		INSERT INTO audit_events (action, actor_id, target_type, target_id, ip, request_id, metadata)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`
+
+	if e.Metadata == nil {
+		e.Metadata = map[string]any{}
+	}
+	metadata, err := json.Marshal(e.Metadata)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(
+		ctx,
+		query,
+		e.Action,
+		e.ActorId,
+		e.TargetType,
+		e.TargetId,
+		e.IP,
+		e.RequestId,
+		metadata,
+	).Scan(&e.Id, &e.CreatedAt)
+}
+
+// Get returns the events matching aq, newest first.
+func (s *AuditStore) Get(ctx context.Context, aq AuditQuery) ([]AuditEvent, error) {
+	query := `
+		SELECT id, action, actor_id, target_type, target_id, ip, request_id, metadata, created_at
+		FROM audit_events
+		WHERE
+			($1 = '' OR action = $1) AND
+			($2::bigint IS NULL OR actor_id = $2) AND
+			($3 = '' OR target_type = $3) AND
+			($4::bigint IS NULL OR target_id = $4) AND
+			($5::timestamptz IS NULL OR created_at >= $5) AND
+			($6::timestamptz IS NULL OR created_at < $6) AND
+			($7 = 0 OR id < $7)
+		ORDER BY id DESC
+		LIMIT $8
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		query,
+		aq.Action,
+		aq.ActorId,
+		aq.TargetType,
+		aq.TargetId,
+		aq.Since,
+		aq.Until,
+		aq.Before,
+		aq.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []AuditEvent{}
+	for rows.Next() {
+		var e AuditEvent
+		var metadata []byte
+		err := rows.Scan(&e.Id, &e.Action, &e.ActorId, &e.TargetType, &e.TargetId, &e.IP, &e.RequestId, &metadata, &e.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(metadata, &e.Metadata); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
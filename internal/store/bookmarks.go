@@ -0,0 +1,286 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// BookmarkCollection is a named group of bookmarks. Collections are private
+// to their owner unless Shared is set.
+type BookmarkCollection struct {
+	Id        int64  `json:"id"`
+	UserId    int64  `json:"user_id"`
+	Name      string `json:"name"`
+	Shared    bool   `json:"shared"`
+	Count     int    `json:"count"`
+	CreatedAt string `json:"created_at"`
+}
+
+type Bookmark struct {
+	PostMetaData
+	CollectionId *int64    `json:"collection_id"`
+	BookmarkedAt time.Time `json:"bookmarked_at"`
+}
+
+// BookmarkCursor points at the last bookmark of a page. Bookmarks are listed
+// newest first, so the next page starts right after it.
+type BookmarkCursor struct {
+	CreatedAt time.Time `json:"t"`
+	PostId    int64     `json:"p"`
+}
+
+type BookmarkQuery struct {
+	// UserId owns the bookmarks being listed
+	UserId int64
+	// CollectionId limits the list to one collection
+	CollectionId *int64
+	After        *BookmarkCursor
+	Limit        int
+}
+
+type BookmarkStore struct {
+	db *sql.DB
+}
+
+// Add bookmarks postID for the user, moving an existing bookmark into
+// collectionID. A nil collectionID leaves the bookmark outside any
+// collection. ErrNotFound is returned when the collection isn't the user's.
+func (s *BookmarkStore) Add(ctx context.Context, userID, postID int64, collectionID *int64) error {
+	query := `
+		INSERT INTO bookmarks (user_id, post_id, collection_id)
+		SELECT $1::bigint, $2::bigint, $3::bigint
+		WHERE $3::bigint IS NULL OR EXISTS (
+			SELECT 1 FROM bookmark_collections bc WHERE bc.id = $3 AND bc.user_id = $1
+		)
+		ON CONFLICT (user_id, post_id) DO UPDATE SET collection_id = EXCLUDED.collection_id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, postID, collectionID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *BookmarkStore) Remove(ctx context.Context, userID, postID int64) error {
+	query := `DELETE FROM bookmarks WHERE user_id = $1 AND post_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, postID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *BookmarkStore) IsBookmarked(ctx context.Context, userID, postID int64) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM bookmarks WHERE user_id = $1 AND post_id = $2)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var bookmarked bool
+	if err := s.db.QueryRowContext(ctx, query, userID, postID).Scan(&bookmarked); err != nil {
+		return false, err
+	}
+
+	return bookmarked, nil
+}
+
+// Get returns a page of bq.UserId's bookmarks, newest first, as seen by
+// viewerID. Bookmarked posts that were deleted or that the viewer can no
+// longer see are left out.
+func (s *BookmarkStore) Get(ctx context.Context, viewerID int64, bq BookmarkQuery) ([]Bookmark, error) {
+	var afterTime *time.Time
+	var afterID int64
+	if bq.After != nil {
+		afterTime, afterID = &bq.After.CreatedAt, bq.After.PostId
+	}
+
+	query := `
+		SELECT ` + postListColumns("p") + `, b.collection_id, b.created_at
+		FROM bookmarks b
+		JOIN posts p ON p.id = b.post_id ` + postListJoins("p", "$1") + `
+		WHERE
+			b.user_id = $2 AND ($3::bigint IS NULL OR b.collection_id = $3) AND
+			($4::timestamptz IS NULL OR (b.created_at, b.post_id) < ($4, $5)) AND
+			p.deleted_at IS NULL AND p.status = 'published' AND ` + visibleTo("p", "$1") + `
+		ORDER BY b.created_at DESC, b.post_id DESC
+		LIMIT $6
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, viewerID, bq.UserId, bq.CollectionId, afterTime, afterID, bq.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bookmarks := []Bookmark{}
+	for rows.Next() {
+		var b Bookmark
+		if err := scanPostListRow(rows, &b.PostMetaData, &b.CollectionId, &b.BookmarkedAt); err != nil {
+			return nil, err
+		}
+		bookmarks = append(bookmarks, b)
+	}
+
+	return bookmarks, rows.Err()
+}
+
+func (s *BookmarkStore) CreateCollection(ctx context.Context, c *BookmarkCollection) error {
+	query := `
+		INSERT INTO bookmark_collections (user_id, name, shared)
+		VALUES ($1, $2, $3) RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, c.UserId, c.Name, c.Shared).Scan(&c.Id, &c.CreatedAt)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrConflict
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *BookmarkStore) GetCollections(ctx context.Context, userID int64) ([]BookmarkCollection, error) {
+	query := `
+		SELECT bc.id, bc.user_id, bc.name, bc.shared, bc.created_at,
+			(SELECT COUNT(*) FROM bookmarks b WHERE b.collection_id = bc.id)
+		FROM bookmark_collections bc
+		WHERE bc.user_id = $1
+		ORDER BY bc.name
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collections := []BookmarkCollection{}
+	for rows.Next() {
+		var c BookmarkCollection
+		if err := rows.Scan(&c.Id, &c.UserId, &c.Name, &c.Shared, &c.CreatedAt, &c.Count); err != nil {
+			return nil, err
+		}
+		collections = append(collections, c)
+	}
+
+	return collections, rows.Err()
+}
+
+func (s *BookmarkStore) GetCollection(ctx context.Context, id int64) (*BookmarkCollection, error) {
+	query := `
+		SELECT bc.id, bc.user_id, bc.name, bc.shared, bc.created_at,
+			(SELECT COUNT(*) FROM bookmarks b WHERE b.collection_id = bc.id)
+		FROM bookmark_collections bc
+		WHERE bc.id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var c BookmarkCollection
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&c.Id, &c.UserId, &c.Name, &c.Shared, &c.CreatedAt, &c.Count)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &c, nil
+}
+
+func (s *BookmarkStore) UpdateCollection(ctx context.Context, c *BookmarkCollection) error {
+	query := `
+		UPDATE bookmark_collections
+		SET name = $1, shared = $2
+		WHERE id = $3 AND user_id = $4
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, c.Name, c.Shared, c.Id, c.UserId)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrConflict
+		}
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// DeleteCollection removes a collection. Its bookmarks are kept outside any
+// collection.
+func (s *BookmarkStore) DeleteCollection(ctx context.Context, userID, id int64) error {
+	query := `DELETE FROM bookmark_collections WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/lunatictiol/go-based-social-media/internal/store"
+)
+
+type ExploreStore struct {
+	rdb *redis.Client
+}
+
+// ExploreExpTime is how long a computed explore page is served.
+const ExploreExpTime = time.Second * 30
+
+const exploreKey = "explore"
+
+func (s *ExploreStore) Get(ctx context.Context) (*store.ExplorePage, error) {
+	data, err := s.rdb.Get(ctx, exploreKey).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var page store.ExplorePage
+	if err := json.Unmarshal([]byte(data), &page); err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+func (s *ExploreStore) Set(ctx context.Context, page *store.ExplorePage) error {
+	json, err := json.Marshal(page)
+	if err != nil {
+		return err
+	}
+
+	return s.rdb.SetEX(ctx, exploreKey, json, ExploreExpTime).Err()
+}
@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/lunatictiol/go-based-social-media/internal/store"
+)
+
+type LinkPreviewStore struct {
+	rdb *redis.Client
+}
+
+const LinkPreviewExpTime = time.Hour * 24
+
+// urls can be long, keys use their hash
+func linkPreviewKey(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return "preview-" + hex.EncodeToString(sum[:])
+}
+
+func (s *LinkPreviewStore) Get(ctx context.Context, url string) (*store.LinkPreview, error) {
+	data, err := s.rdb.Get(ctx, linkPreviewKey(url)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var preview store.LinkPreview
+	if err := json.Unmarshal([]byte(data), &preview); err != nil {
+		return nil, err
+	}
+
+	return &preview, nil
+}
+
+func (s *LinkPreviewStore) Set(ctx context.Context, preview *store.LinkPreview) error {
+	json, err := json.Marshal(preview)
+	if err != nil {
+		return err
+	}
+
+	return s.rdb.SetEX(ctx, linkPreviewKey(preview.URL), json, LinkPreviewExpTime).Err()
+}
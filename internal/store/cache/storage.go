@@ -13,10 +13,28 @@ type Storage struct {
 		Set(context.Context, *store.User) error
 		Delete(context.Context, int64)
 	}
+	LinkPreviews interface {
+		Get(ctx context.Context, url string) (*store.LinkPreview, error)
+		Set(ctx context.Context, preview *store.LinkPreview) error
+	}
+	Explore interface {
+		Get(context.Context) (*store.ExplorePage, error)
+		Set(context.Context, *store.ExplorePage) error
+	}
+	Timelines interface {
+		Len(ctx context.Context, userID int64) (int, bool, error)
+		Rebuild(ctx context.Context, userID int64, entries []store.TimelineEntry) error
+		Push(ctx context.Context, userIDs []int64, entries ...store.TimelineEntry) error
+		Remove(ctx context.Context, userIDs []int64, postIDs []int64) error
+		Page(ctx context.Context, userID int64, cursor *store.PostCursor, newer bool, count int) ([]store.TimelineEntry, error)
+	}
 }
 
 func NewRedisStorage(rbd *redis.Client) Storage {
 	return Storage{
-		Users: &UserStore{rdb: rbd},
+		Users:        &UserStore{rdb: rbd},
+		LinkPreviews: &LinkPreviewStore{rdb: rbd},
+		Explore:      &ExploreStore{rdb: rbd},
+		Timelines:    &TimelineStore{rdb: rbd},
 	}
 }
@@ -0,0 +1,194 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/lunatictiol/go-based-social-media/internal/store"
+)
+
+// TimelineStore keeps home timelines as sorted sets of post ids scored by
+// their creation time in microseconds.
+type TimelineStore struct {
+	rdb *redis.Client
+}
+
+const (
+	// TimelineLength is how many posts a timeline keeps, older pages are
+	// read from Postgres
+	TimelineLength = 800
+	// TimelineExpTime is how long the timeline of a user who stopped reading
+	// it is kept up to date
+	TimelineExpTime = time.Hour * 24 * 7
+)
+
+// Built timelines hold a sentinel scored -inf so a timeline without posts
+// can be told apart from one that was never built. It always has rank 0.
+const timelineSentinel = "built"
+
+// pushScript adds posts to a timeline that is already built and trims it to
+// ARGV[1] posts. ARGV[2:] are score and post id pairs.
+var pushScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+for i = 2, #ARGV, 2 do
+	redis.call('ZADD', KEYS[1], ARGV[i], ARGV[i + 1])
+end
+redis.call('ZREMRANGEBYRANK', KEYS[1], 1, -tonumber(ARGV[1]) - 1)
+return 1
+`)
+
+func timelineKey(userID int64) string {
+	return fmt.Sprintf("timeline-%d", userID)
+}
+
+func timelineScore(t time.Time) float64 {
+	return float64(t.UnixMicro())
+}
+
+// Len returns how many posts the timeline of userID holds, built is false
+// when it has to be rebuilt first. Reading a timeline keeps it from
+// expiring.
+func (s *TimelineStore) Len(ctx context.Context, userID int64) (int, bool, error) {
+	key := timelineKey(userID)
+
+	var card *redis.IntCmd
+	_, err := s.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		card = pipe.ZCard(ctx, key)
+		pipe.Expire(ctx, key, TimelineExpTime)
+		return nil
+	})
+	if err != nil {
+		return 0, false, err
+	}
+
+	n := card.Val()
+	if n == 0 {
+		return 0, false, nil
+	}
+	return int(n) - 1, true, nil
+}
+
+// Rebuild replaces the timeline of userID with entries.
+func (s *TimelineStore) Rebuild(ctx context.Context, userID int64, entries []store.TimelineEntry) error {
+	key := timelineKey(userID)
+
+	members := []*redis.Z{{Score: math.Inf(-1), Member: timelineSentinel}}
+	for _, e := range entries {
+		members = append(members, &redis.Z{Score: timelineScore(e.CreatedAt), Member: e.PostId})
+	}
+
+	_, err := s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		pipe.ZAdd(ctx, key, members...)
+		pipe.ZRemRangeByRank(ctx, key, 1, -TimelineLength-1)
+		pipe.Expire(ctx, key, TimelineExpTime)
+		return nil
+	})
+
+	return err
+}
+
+// Push adds entries to the timelines of userIDs. Timelines that aren't
+// built are left alone, they're rebuilt from Postgres when next read.
+func (s *TimelineStore) Push(ctx context.Context, userIDs []int64, entries ...store.TimelineEntry) error {
+	if len(userIDs) == 0 || len(entries) == 0 {
+		return nil
+	}
+
+	args := []any{TimelineLength}
+	for _, e := range entries {
+		args = append(args, timelineScore(e.CreatedAt), e.PostId)
+	}
+
+	_, err := s.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, id := range userIDs {
+			pushScript.Eval(ctx, pipe, []string{timelineKey(id)}, args...)
+		}
+		return nil
+	})
+
+	return err
+}
+
+// Remove takes postIDs out of the timelines of userIDs.
+func (s *TimelineStore) Remove(ctx context.Context, userIDs []int64, postIDs []int64) error {
+	if len(userIDs) == 0 || len(postIDs) == 0 {
+		return nil
+	}
+
+	members := make([]any, len(postIDs))
+	for i, id := range postIDs {
+		members[i] = id
+	}
+
+	_, err := s.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, id := range userIDs {
+			pipe.ZRem(ctx, timelineKey(id), members...)
+		}
+		return nil
+	})
+
+	return err
+}
+
+// Page returns up to count entries of the timeline of userID that come
+// after cursor, older ones unless newer is set. Entries are ordered walking
+// away from the cursor, a nil cursor starts from the newest post.
+func (s *TimelineStore) Page(ctx context.Context, userID int64, cursor *store.PostCursor, newer bool, count int) ([]store.TimelineEntry, error) {
+	key := timelineKey(userID)
+
+	// posts are scored above 0, which leaves the sentinel out
+	by := &redis.ZRangeBy{Min: "0", Max: "+inf", Count: int64(count)}
+	var cursorScore float64
+	if cursor != nil {
+		t, err := time.Parse(time.RFC3339Nano, cursor.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		// bounds are inclusive so posts created in the same microsecond as
+		// the cursor's are kept, their ids decide which side they're on
+		cursorScore = timelineScore(t)
+		if newer {
+			by.Min = strconv.FormatFloat(cursorScore, 'f', -1, 64)
+		} else {
+			by.Max = strconv.FormatFloat(cursorScore, 'f', -1, 64)
+		}
+	}
+
+	entries := []store.TimelineEntry{}
+	for {
+		var zs []redis.Z
+		var err error
+		if newer {
+			zs, err = s.rdb.ZRangeByScoreWithScores(ctx, key, by).Result()
+		} else {
+			zs, err = s.rdb.ZRevRangeByScoreWithScores(ctx, key, by).Result()
+		}
+		if err != nil {
+			return nil, err
+		}
+		by.Offset += int64(len(zs))
+
+		for _, z := range zs {
+			member, _ := z.Member.(string)
+			id, err := strconv.ParseInt(member, 10, 64)
+			if err != nil {
+				continue
+			}
+			if cursor != nil && z.Score == cursorScore && (newer && id <= cursor.Id || !newer && id >= cursor.Id) {
+				continue
+			}
+			entries = append(entries, store.TimelineEntry{PostId: id, CreatedAt: time.UnixMicro(int64(z.Score))})
+		}
+
+		if len(entries) >= count || len(zs) < count {
+			return entries, nil
+		}
+	}
+}
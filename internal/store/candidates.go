@@ -0,0 +1,88 @@
+package store
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// FeedCandidate is a post that can be ranked into the For You feed, with
+// the signals it is ranked on besides its age and counts.
+type FeedCandidate struct {
+	PostMetaData
+	Bookmarks int
+	// Interactions counts the viewer's comments on, reposts of and bookmarks
+	// of posts by the author of the candidate's content
+	Interactions int
+	// MatchedTags counts the candidate's tags that the viewer follows
+	MatchedTags int
+}
+
+type CandidateQuery struct {
+	// Since and Until bound the creation time of candidates, Until is
+	// inclusive so a ranking can be replayed without newer posts
+	Since time.Time
+	Until time.Time
+	Limit int
+}
+
+// GetFeedCandidates returns the newest posts of the feed of viewerID created
+// inside the query's window, each piece of content once.
+func (s *PostStore) GetFeedCandidates(ctx context.Context, viewerID int64, cq CandidateQuery) ([]FeedCandidate, error) {
+	// candidates are the chronological feed from Until down to Since. The
+	// cursor at Until has an id past every post's and is compared with <=
+	// so content appearing again after Until isn't dropped, which keeps
+	// rankings replayable
+	query := feedCTE(`p.created_at > $7 AND (`+feedCandidates+`)`, "<=") + `,
+		affinity AS (
+			SELECT i.author_id, COUNT(*) AS interactions
+			FROM (
+				SELECT ip.user_id AS author_id
+				FROM comments ic JOIN posts ip ON ip.id = ic.post_id
+				WHERE ic.user_id = $1
+				UNION ALL
+				SELECT io.user_id
+				FROM posts ir JOIN posts io ON io.id = ir.repost_of
+				WHERE ir.user_id = $1 AND ir.deleted_at IS NULL
+				UNION ALL
+				SELECT ib.user_id
+				FROM bookmarks b JOIN posts ib ON ib.id = b.post_id
+				WHERE b.user_id = $1
+			) i
+			WHERE i.author_id <> $1
+			GROUP BY i.author_id
+		)
+		SELECT ` + postListColumns("f") + `,
+			(SELECT COUNT(*) FROM bookmarks cb WHERE cb.post_id = ` + contentID("f") + `),
+			COALESCE(af.interactions, 0),
+			cardinality(ARRAY(
+				SELECT lower(unnest(CASE WHEN f.kind = 'repost' THEN lo.tags ELSE f.tags END))
+				INTERSECT
+				SELECT ct.tag FROM tag_follows ct WHERE ct.user_id = $1
+			))
+		FROM feed f ` + postListJoins("f", "$1") + `
+		LEFT JOIN affinity af ON af.author_id = (CASE WHEN f.kind = 'repost' THEN lo.user_id ELSE f.user_id END)
+		ORDER BY f.created_at DESC, f.id DESC
+		LIMIT $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, viewerID, cq.Limit, "", nil, cq.Until, int64(math.MaxInt64), cq.Since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	candidates := []FeedCandidate{}
+	for rows.Next() {
+		var c FeedCandidate
+		if err := scanPostListRow(rows, &c.PostMetaData, &c.Bookmarks, &c.Interactions, &c.MatchedTags); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, c)
+	}
+
+	return candidates, rows.Err()
+}
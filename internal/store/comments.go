@@ -3,15 +3,19 @@ package store
 import (
 	"context"
 	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/lunatictiol/go-based-social-media/internal/entities"
 )
 
 type Comment struct {
-	Id        int64  `json:"id"`
-	PostId    int64  `json:"post_id"`
-	UserId    int64  `json:"use_id"`
-	Content   string `json:"content"`
-	CreatedAt string `json:"created_at"`
-	User      User   `json:"user"`
+	Id        int64             `json:"id"`
+	PostId    int64             `json:"post_id"`
+	UserId    int64             `json:"use_id"`
+	Content   string            `json:"content"`
+	CreatedAt string            `json:"created_at"`
+	User      User              `json:"user"`
+	Entities  []entities.Entity `json:"entities"`
 }
 
 type CommentStore struct {
@@ -25,35 +29,70 @@ func (s *CommentStore) Create(ctx context.Context, comment *Comment) error {
 		RETURNING id, created_at
 	`
 
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
-	defer cancel()
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
 
-	err := s.db.QueryRowContext(
-		ctx,
-		query,
-		comment.PostId,
-		comment.UserId,
-		comment.Content,
-	).Scan(
-		&comment.Id,
-		&comment.CreatedAt,
-	)
-	if err != nil {
-		return err
+		err := tx.QueryRowContext(
+			ctx,
+			query,
+			comment.PostId,
+			comment.UserId,
+			comment.Content,
+		).Scan(
+			&comment.Id,
+			&comment.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
+
+		return s.createEntities(ctx, tx, comment)
+	})
+}
+
+// createEntities records the users mentioned and the hashtags used in the
+// comment.
+func (s *CommentStore) createEntities(ctx context.Context, tx *sql.Tx, comment *Comment) error {
+	if mentions := entities.Mentions(comment.Entities); len(mentions) > 0 {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO comment_mentions (comment_id, user_id)
+			SELECT $1, id FROM users WHERE username = ANY($2)
+			ON CONFLICT DO NOTHING
+		`, comment.Id, pq.Array(mentions))
+		if err != nil {
+			return err
+		}
+	}
+
+	if hashtags := entities.Hashtags(comment.Entities); len(hashtags) > 0 {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO comment_hashtags (comment_id, tag)
+			SELECT $1, unnest($2::varchar[])
+		`, comment.Id, pq.Array(hashtags))
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-func (s *CommentStore) GetByPostID(ctx context.Context, postID int64) ([]Comment, error) {
+// GetByPostID returns the comments on the post, leaving out those matching
+// the viewer's muted words, those hidden by moderators and those of
+// suspended users, unless the viewer wrote them.
+func (s *CommentStore) GetByPostID(ctx context.Context, postID, viewerID int64) ([]Comment, error) {
 	query := `
 		SELECT c.id, c.post_id, c.user_id, c.content, c.created_at, users.username, users.id  FROM comments c
 		JOIN users on users.id = c.user_id
-		WHERE c.post_id = $1
+		WHERE
+			c.post_id = $1 AND
+			(c.user_id = $2 OR (c.hidden_at IS NULL AND ` + notSuspended("c.user_id") + `)) AND
+			` + notMuted("c.content", "c.user_id", "$2") + `
 		ORDER BY c.created_at DESC;
 	`
 
-	rows, err := s.db.QueryContext(ctx, query, postID)
+	rows, err := s.db.QueryContext(ctx, query, postID, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -67,6 +106,7 @@ func (s *CommentStore) GetByPostID(ctx context.Context, postID int64) ([]Comment
 		if err != nil {
 			return nil, err
 		}
+		c.Entities = entities.Parse(c.Content)
 		comments = append(comments, c)
 	}
 
@@ -0,0 +1,70 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// ExplorePage is the global timeline shown to everyone, signed in or not.
+// It only holds public posts and is built without a viewer so a single copy
+// can be cached for all of them.
+type ExplorePage struct {
+	Recent  []PostMetaData `json:"recent"`
+	Popular []PostMetaData `json:"popular"`
+	// Tags holds a section of recent posts for each trending tag
+	Tags        []ExploreSection `json:"tags"`
+	GeneratedAt time.Time        `json:"generated_at"`
+}
+
+type ExploreSection struct {
+	Tag   string         `json:"tag"`
+	Posts []PostMetaData `json:"posts"`
+}
+
+// GetPublicRecent returns the newest public posts of all users. Pure
+// reposts are left out so every post shows up once.
+func (s *PostStore) GetPublicRecent(ctx context.Context, limit int) ([]PostMetaData, error) {
+	query := `
+		SELECT ` + postListColumns("p") + `
+		FROM posts p ` + postListJoins("p", "0") + `
+		WHERE
+			p.deleted_at IS NULL AND p.status = 'published' AND p.visibility = 'public' AND
+			p.hidden_at IS NULL AND ` + notSuspended("p.user_id") + ` AND p.kind <> 'repost'
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT $1
+	`
+
+	return s.queryPublic(ctx, query, limit)
+}
+
+// GetPublicPopular returns the public posts created since then with the
+// most comments and reposts, reposts counting twice.
+func (s *PostStore) GetPublicPopular(ctx context.Context, since time.Time, limit int) ([]PostMetaData, error) {
+	query := `
+		SELECT ` + postListColumns("p") + `
+		FROM posts p ` + postListJoins("p", "0") + `
+		WHERE
+			p.deleted_at IS NULL AND p.status = 'published' AND p.visibility = 'public' AND
+			p.hidden_at IS NULL AND ` + notSuspended("p.user_id") + ` AND p.kind <> 'repost' AND p.created_at > $2
+		ORDER BY
+			(SELECT COUNT(*) FROM comments ec WHERE ec.post_id = p.id) +
+			2 * (SELECT COUNT(*) FROM posts er WHERE er.repost_of = p.id AND er.kind = 'repost' AND er.deleted_at IS NULL) DESC,
+			p.created_at DESC, p.id DESC
+		LIMIT $1
+	`
+
+	return s.queryPublic(ctx, query, limit, since)
+}
+
+func (s *PostStore) queryPublic(ctx context.Context, query string, args ...any) ([]PostMetaData, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPostList(rows)
+}
@@ -0,0 +1,78 @@
+package store
+
+import (
+	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/lunatictiol/go-based-social-media/internal/entities"
+)
+
+// The endpoints that return lists of posts share the same row shape: the
+// post, its author, comment and repost counts, whether the viewer bookmarked
+// it, its link previews and, for reposts and quotes, the shared post when the
+// viewer can see it. postListColumns and
+// postListJoins build that shape around any relation aliased as post, and
+// scanPostList reads it back.
+
+// contentID is the post whose content a row shows, the original for pure
+// reposts and the post itself otherwise.
+func contentID(post string) string {
+	return `(CASE WHEN ` + post + `.kind = 'repost' THEN ` + post + `.repost_of ELSE ` + post + `.id END)`
+}
+
+func postListColumns(post string) string {
+	return `
+		` + post + `.id, ` + post + `.user_id, ` + post + `.title, ` + post + `.content, ` + post + `.content_format, ` + post + `.content_html, ` + post + `.created_at, ` + post + `.version, ` + post + `.tags,
+		` + post + `.kind, ` + post + `.repost_of, ` + post + `.visibility,
+		lu.username,
+		(SELECT COUNT(*) FROM comments lc WHERE lc.post_id = ` + contentID(post) + `) AS comments_count,
+		(SELECT COUNT(*) FROM posts lr WHERE lr.repost_of = ` + contentID(post) + ` AND lr.kind = 'repost' AND lr.deleted_at IS NULL) AS repost_count,
+		lb.post_id IS NOT NULL AS bookmarked,
+		` + postPreviews(post) + ` AS previews,
+		lo.id, lo.user_id, lo.title, lo.content, lo.content_format, lo.content_html, lo.created_at, lo.tags, lo.visibility, lou.username`
+}
+
+func postListJoins(post, viewer string) string {
+	return `
+		JOIN users lu ON lu.id = ` + post + `.user_id
+		LEFT JOIN bookmarks lb ON lb.user_id = ` + viewer + ` AND lb.post_id = ` + contentID(post) + `
+		LEFT JOIN posts lo ON lo.id = ` + post + `.repost_of AND lo.deleted_at IS NULL AND ` + visibleTo("lo", viewer) + `
+		LEFT JOIN users lou ON lou.id = lo.user_id`
+}
+
+func scanPostList(rows *sql.Rows) ([]PostMetaData, error) {
+	posts := []PostMetaData{}
+	for rows.Next() {
+		var p PostMetaData
+		if err := scanPostListRow(rows, &p); err != nil {
+			return nil, err
+		}
+		posts = append(posts, p)
+	}
+
+	return posts, rows.Err()
+}
+
+// scanPostListRow scans the current row into p. Columns selected after
+// postListColumns are scanned into extra.
+func scanPostListRow(rows *sql.Rows, p *PostMetaData, extra ...any) error {
+	var original originalPost
+	var previews []byte
+	dest := []any{
+		&p.Post.Id, &p.Post.UserId, &p.Post.Title, &p.Post.Content, &p.Post.ContentFormat, &p.Post.ContentHTML, &p.Post.CreatedAt, &p.Post.Version, pq.Array(&p.Post.Tags),
+		&p.Post.Kind, &p.Post.RepostOf, &p.Post.Visibility,
+		&p.Post.User.Username, &p.CommentCount, &p.Post.RepostCount, &p.Post.Bookmarked, &previews,
+		&original.id, &original.userID, &original.title, &original.content, &original.contentFormat, &original.contentHTML, &original.createdAt, pq.Array(&original.tags), &original.visibility, &original.username,
+	}
+	if err := rows.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+
+	p.Post.User.Id = p.Post.UserId
+	p.Post.Entities = entities.Parse(p.Post.Content)
+	p.Post.Original = original.toPost()
+
+	var err error
+	p.Post.Previews, err = parsePreviews(previews)
+	return err
+}
@@ -0,0 +1,74 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// FeedMarker is the newest feed post a user has seen. It is kept on the
+// server so all of the user's devices share it.
+type FeedMarker struct {
+	UserId    int64
+	Position  PostCursor
+	UpdatedAt string
+}
+
+type FeedMarkerStore struct {
+	db *sql.DB
+}
+
+func (s *FeedMarkerStore) Get(ctx context.Context, userID int64) (*FeedMarker, error) {
+	query := `
+		SELECT post_id, post_created_at, updated_at
+		FROM feed_markers
+		WHERE user_id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	m := FeedMarker{UserId: userID}
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&m.Position.Id, &m.Position.CreatedAt, &m.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &m, nil
+}
+
+// Set moves the marker of m.UserId to m.Position. Markers only move
+// forward, a device that is behind can't take the others back, m is
+// updated to the stored marker.
+func (s *FeedMarkerStore) Set(ctx context.Context, m *FeedMarker) error {
+	query := `
+		INSERT INTO feed_markers (user_id, post_id, post_created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE
+		SET post_id = EXCLUDED.post_id, post_created_at = EXCLUDED.post_created_at, updated_at = NOW()
+		WHERE (EXCLUDED.post_created_at, EXCLUDED.post_id) > (feed_markers.post_created_at, feed_markers.post_id)
+		RETURNING post_id, post_created_at, updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, m.UserId, m.Position.Id, m.Position.CreatedAt).
+		Scan(&m.Position.Id, &m.Position.CreatedAt, &m.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		// the stored marker is ahead
+		stored, err := s.Get(ctx, m.UserId)
+		if err != nil {
+			return err
+		}
+		*m = *stored
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,176 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// MaxMutedWords is how many words and phrases a user can mute at once.
+const MaxMutedWords = 200
+
+var ErrMuteLimit = errors.New("at most 200 words can be muted")
+
+type MutedWord struct {
+	Id     int64  `json:"id"`
+	UserId int64  `json:"user_id"`
+	Phrase string `json:"phrase"`
+	// WholeWord only matches the phrase on its own, muting "cat" doesn't
+	// hide posts about "category"
+	WholeWord bool       `json:"whole_word"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	CreatedAt string     `json:"created_at"`
+}
+
+type MutedWordStore struct {
+	db *sql.DB
+}
+
+// Create mutes w.Phrase for w.UserId. Muting a phrase again, in any case,
+// updates the existing mute. Expired mutes of the user are dropped on the
+// way.
+func (s *MutedWordStore) Create(ctx context.Context, w *MutedWord) error {
+	w.Phrase = strings.Join(strings.Fields(w.Phrase), " ")
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		// serializes concurrent mutes of the same user
+		if _, err := tx.ExecContext(ctx, `SELECT id FROM users WHERE id = $1 FOR UPDATE`, w.UserId); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, `DELETE FROM muted_words WHERE user_id = $1 AND expires_at <= NOW()`, w.UserId)
+		if err != nil {
+			return err
+		}
+
+		var count int
+		var exists bool
+		err = tx.QueryRowContext(ctx, `
+			SELECT COUNT(*), COALESCE(bool_or(lower(phrase) = lower($2)), false)
+			FROM muted_words
+			WHERE user_id = $1
+		`, w.UserId, w.Phrase).Scan(&count, &exists)
+		if err != nil {
+			return err
+		}
+		if !exists && count >= MaxMutedWords {
+			return ErrMuteLimit
+		}
+
+		return tx.QueryRowContext(ctx, `
+			INSERT INTO muted_words (user_id, phrase, whole_word, pattern, expires_at)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (user_id, lower(phrase)) DO UPDATE
+			SET phrase = EXCLUDED.phrase, whole_word = EXCLUDED.whole_word, pattern = EXCLUDED.pattern, expires_at = EXCLUDED.expires_at
+			RETURNING id, created_at
+		`, w.UserId, w.Phrase, w.WholeWord, mutePattern(w.Phrase, w.WholeWord), w.ExpiresAt).Scan(&w.Id, &w.CreatedAt)
+	})
+}
+
+// GetByUser returns the user's active mutes, most recent first.
+func (s *MutedWordStore) GetByUser(ctx context.Context, userID int64) ([]MutedWord, error) {
+	query := `
+		SELECT id, user_id, phrase, whole_word, expires_at, created_at
+		FROM muted_words
+		WHERE user_id = $1 AND (expires_at IS NULL OR expires_at > NOW())
+		ORDER BY created_at DESC, id DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	words := []MutedWord{}
+	for rows.Next() {
+		var w MutedWord
+		if err := rows.Scan(&w.Id, &w.UserId, &w.Phrase, &w.WholeWord, &w.ExpiresAt, &w.CreatedAt); err != nil {
+			return nil, err
+		}
+		words = append(words, w)
+	}
+
+	return words, rows.Err()
+}
+
+func (s *MutedWordStore) Delete(ctx context.Context, userID, id int64) error {
+	query := `DELETE FROM muted_words WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// mutePattern compiles phrase to the Postgres regular expression matched,
+// case-insensitively, against the text of posts and comments. The phrase is
+// matched literally, any run of whitespace matches the spaces between its
+// words.
+func mutePattern(phrase string, wholeWord bool) string {
+	words := strings.Fields(phrase)
+	for i, w := range words {
+		// the characters QuoteMeta escapes are the special ones of Postgres
+		// regular expressions too, and an escaped punctuation character
+		// always stands for itself
+		words[i] = regexp.QuoteMeta(w)
+	}
+	pattern := strings.Join(words, `[[:space:]]+`)
+
+	if wholeWord {
+		// \m and \M only work for phrases starting and ending with a word
+		// character, "c++" or "#go" wouldn't match
+		pattern = `(^|[^[:alnum:]_])` + pattern + `([^[:alnum:]_]|$)`
+	}
+
+	return pattern
+}
+
+// notMuted returns the SQL condition under which text, written by author,
+// matches none of the active mutes of the user id bound to the viewer
+// placeholder. The viewer's own writing is never hidden from them.
+func notMuted(text, author, viewer string) string {
+	return `(
+		` + author + ` = ` + viewer + ` OR NOT EXISTS (
+			SELECT 1 FROM muted_words mw
+			WHERE
+				mw.user_id = ` + viewer + ` AND (mw.expires_at IS NULL OR mw.expires_at > NOW()) AND
+				` + text + ` ~* mw.pattern
+		)
+	)`
+}
+
+// postText is the text of the post aliased as post that mutes are matched
+// against.
+func postText(post string) string {
+	return `concat_ws(' ', ` + post + `.title, ` + post + `.content, array_to_string(` + post + `.tags, ' '))`
+}
+
+// postNotMuted is notMuted for the post aliased as post and the shared post
+// aliased as original, which may be missing.
+func postNotMuted(post, original, viewer string) string {
+	return notMuted(postText(post), post+`.user_id`, viewer) + ` AND
+		(` + original + `.id IS NULL OR ` + notMuted(postText(original), original+`.user_id`, viewer) + `)`
+}
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestMutePattern(t *testing.T) {
+	tests := []struct {
+		phrase    string
+		wholeWord bool
+		want      string
+	}{
+		{"cat", false, `cat`},
+		{"cat", true, `(^|[^[:alnum:]_])cat([^[:alnum:]_]|$)`},
+		{"  spoiler   alert ", false, `spoiler[[:space:]]+alert`},
+		{"c++", false, `c\+\+`},
+		{"a.b*c?", false, `a\.b\*c\?`},
+		{`(x|y)[z]{2}^$\`, false, `\(x\|y\)\[z\]\{2\}\^\$\\`},
+		{"#go", true, `(^|[^[:alnum:]_])#go([^[:alnum:]_]|$)`},
+	}
+
+	for _, tt := range tests {
+		if got := mutePattern(tt.phrase, tt.wholeWord); got != tt.want {
+			t.Errorf("mutePattern(%q, %v) = %s, want %s", tt.phrase, tt.wholeWord, got, tt.want)
+		}
+	}
+}
+
+// TestMutePatternMatches runs the patterns with Go's regexp, which agrees
+// with Postgres on the syntax they use for ASCII text.
+func TestMutePatternMatches(t *testing.T) {
+	tests := []struct {
+		phrase    string
+		wholeWord bool
+		text      string
+		want      bool
+	}{
+		{"cat", false, "my Cat is great", true},
+		{"cat", false, "a category", true},
+		{"cat", true, "a category", false},
+		{"cat", true, "cat.", true},
+		{"cat", true, "(CAT)", true},
+		{"cat", true, "cat_videos", false},
+		{"spoiler alert", false, "SPOILER\n\talert", true},
+		{"spoiler alert", false, "spoileralert", false},
+		{"c++", true, "I write C++ daily", true},
+		{"c++", false, "c", false},
+		{"#go", true, "love #go!", true},
+		{"#go", true, "love #golang", false},
+		{"a.b", false, "axb", false},
+		{"a.b", false, "a.b", true},
+		{".*", false, "anything", false},
+	}
+
+	for _, tt := range tests {
+		re := regexp.MustCompile(`(?i)` + mutePattern(tt.phrase, tt.wholeWord))
+		if got := re.MatchString(tt.text); got != tt.want {
+			t.Errorf("mute %q (whole word %v) on %q = %v, want %v", tt.phrase, tt.wholeWord, tt.text, got, tt.want)
+		}
+	}
+}
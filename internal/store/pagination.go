@@ -8,13 +8,26 @@ import (
 )
 
 type PaginatedFeedQuery struct {
-	Limit  int      `json:"limit" validation:"gte=1, lte=20"`
-	Offset int      `json:"offset" validation:"gte=0"`
-	Sort   string   `json:"sort" validation:"oneof=asc desc"`
-	Tags   []string `json:"tags" validation:"max=5"`
-	Search string   `json:"search" validation:"max=500"`
+	Limit int `json:"limit" validate:"gte=1,lte=20"`
+	// Offset is only used by tag timelines, the feed pages with After and
+	// Before
+	Offset int      `json:"offset" validate:"gte=0"`
+	Sort   string   `json:"sort" validate:"oneof=asc desc"`
+	Tags   []string `json:"tags" validate:"max=5"`
+	Search string   `json:"search" validate:"max=500"`
 	Since  string   `json:"since"`
 	Until  string   `json:"until"`
+	// After fetches the page following a post in Sort order, Before the
+	// page preceding it. At most one of them is set.
+	After  *PostCursor `json:"-"`
+	Before *PostCursor `json:"-"`
+}
+
+// PostCursor points at the last post of a page of posts listed newest
+// first. The next page starts right after it.
+type PostCursor struct {
+	CreatedAt string `json:"t"`
+	Id        int64  `json:"i"`
 }
 
 func (fq PaginatedFeedQuery) Parse(r *http.Request) (PaginatedFeedQuery, error) {
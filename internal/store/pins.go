@@ -0,0 +1,141 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// MaxPinnedPosts is how many posts a user can pin to their profile.
+const MaxPinnedPosts = 3
+
+var ErrPinLimit = errors.New("at most 3 posts can be pinned")
+
+type PinStore struct {
+	db *sql.DB
+}
+
+// Pin pins the user's post at position, counted from 1, shifting the pins
+// at and after it down. A position of 0, or past the last pin, appends the
+// post. Pinning an already pinned post moves it. Only the user's own live,
+// published posts can be pinned, ErrNotFound is returned for anything else.
+func (s *PinStore) Pin(ctx context.Context, userID, postID int64, position int) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		// serializes concurrent pin changes of the same user
+		if _, err := tx.ExecContext(ctx, `SELECT id FROM users WHERE id = $1 FOR UPDATE`, userID); err != nil {
+			return err
+		}
+
+		var exists bool
+		err := tx.QueryRowContext(ctx, `
+			SELECT EXISTS (
+				SELECT 1 FROM posts
+				WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL AND status = 'published'
+			)
+		`, postID, userID).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrNotFound
+		}
+
+		rows, err := tx.QueryContext(ctx, `SELECT post_id FROM pinned_posts WHERE user_id = $1 ORDER BY position`, userID)
+		if err != nil {
+			return err
+		}
+		pins := []int64{}
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			if id != postID {
+				pins = append(pins, id)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if len(pins) >= MaxPinnedPosts {
+			return ErrPinLimit
+		}
+		if position < 1 || position > len(pins) {
+			position = len(pins) + 1
+		}
+		pins = append(pins[:position-1], append([]int64{postID}, pins[position-1:]...)...)
+
+		// positions are rewritten from scratch to keep them contiguous
+		if _, err := tx.ExecContext(ctx, `DELETE FROM pinned_posts WHERE user_id = $1`, userID); err != nil {
+			return err
+		}
+		for i, id := range pins {
+			_, err := tx.ExecContext(ctx, `INSERT INTO pinned_posts (user_id, post_id, position) VALUES ($1, $2, $3)`, userID, id, i+1)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *PinStore) Unpin(ctx context.Context, userID, postID int64) error {
+	query := `DELETE FROM pinned_posts WHERE user_id = $1 AND post_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, postID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetPinned returns the posts userID pinned that viewerID can see, in pin
+// order.
+func (s *PinStore) GetPinned(ctx context.Context, viewerID, userID int64) ([]PostMetaData, error) {
+	query := `
+		SELECT ` + postListColumns("p") + `
+		FROM pinned_posts pp
+		JOIN posts p ON p.id = pp.post_id ` + postListJoins("p", "$1") + `
+		WHERE
+			pp.user_id = $2 AND p.deleted_at IS NULL AND p.status = 'published' AND
+			` + visibleTo("p", "$1") + `
+		ORDER BY pp.position
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, viewerID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPostList(rows)
+}
+
+// unpinPost drops the post from its author's pins. Positions can be left
+// with a gap, which keeps the order and is closed by the next Pin.
+func unpinPost(ctx context.Context, tx *sql.Tx, postID int64) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM pinned_posts WHERE post_id = $1`, postID)
+	return err
+}
@@ -0,0 +1,187 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrPollClosed  = errors.New("poll is closed")
+	ErrInvalidVote = errors.New("invalid poll options")
+)
+
+// Poll is a set of options attached to a post. Vote counts are only filled
+// in once the viewer voted or the poll closed.
+type Poll struct {
+	Options  []PollOption `json:"options"`
+	Multiple bool         `json:"multiple"`
+	ClosesAt time.Time    `json:"closes_at"`
+	Closed   bool         `json:"closed"`
+	// Voted reports whether the viewer voted
+	Voted bool `json:"voted"`
+	// Voters is how many users voted
+	Voters *int `json:"voters,omitempty"`
+}
+
+type PollOption struct {
+	Id    int64  `json:"id"`
+	Text  string `json:"text"`
+	Votes *int   `json:"votes,omitempty"`
+	// Chosen reports whether the viewer voted for the option
+	Chosen bool `json:"chosen,omitempty"`
+}
+
+type PollStore struct {
+	db *sql.DB
+}
+
+// createPoll stores post.Poll and fills in the ids of its options.
+func createPoll(ctx context.Context, tx *sql.Tx, post *Post) error {
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO polls (post_id, multiple, closes_at) VALUES ($1, $2, $3)`,
+		post.Id, post.Poll.Multiple, post.Poll.ClosesAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	for i := range post.Poll.Options {
+		option := &post.Poll.Options[i]
+		err := tx.QueryRowContext(ctx,
+			`INSERT INTO poll_options (post_id, position, text) VALUES ($1, $2, $3) RETURNING id`,
+			post.Id, i+1, option.Text,
+		).Scan(&option.Id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetByPostID returns the poll of a post as seen by viewerID.
+func (s *PollStore) GetByPostID(ctx context.Context, postID, viewerID int64) (*Poll, error) {
+	query := `
+		SELECT multiple, closes_at, closes_at <= NOW(),
+			EXISTS (SELECT 1 FROM poll_voters WHERE post_id = $1 AND user_id = $2),
+			(SELECT COUNT(*) FROM poll_voters WHERE post_id = $1)
+		FROM polls
+		WHERE post_id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var poll Poll
+	var voters int
+	err := s.db.QueryRowContext(ctx, query, postID, viewerID).Scan(
+		&poll.Multiple,
+		&poll.ClosesAt,
+		&poll.Closed,
+		&poll.Voted,
+		&voters,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT o.id, o.text, COUNT(v.user_id), COALESCE(bool_or(v.user_id = $2), FALSE)
+		FROM poll_options o
+		LEFT JOIN poll_votes v ON v.option_id = o.id
+		WHERE o.post_id = $1
+		GROUP BY o.id
+		ORDER BY o.position
+	`, postID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// results stay hidden until the viewer voted or the poll closed so they
+	// can't sway the vote
+	showResults := poll.Voted || poll.Closed
+	if showResults {
+		poll.Voters = &voters
+	}
+
+	poll.Options = []PollOption{}
+	for rows.Next() {
+		var o PollOption
+		var votes int
+		if err := rows.Scan(&o.Id, &o.Text, &votes, &o.Chosen); err != nil {
+			return nil, err
+		}
+		if showResults {
+			o.Votes = &votes
+		}
+		poll.Options = append(poll.Options, o)
+	}
+
+	return &poll, rows.Err()
+}
+
+// Vote records userID's vote for optionIDs. A user votes once, a second
+// vote returns ErrConflict.
+func (s *PollStore) Vote(ctx context.Context, postID, userID int64, optionIDs []int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var multiple, closed bool
+		err := tx.QueryRowContext(ctx,
+			`SELECT multiple, closes_at <= NOW() FROM polls WHERE post_id = $1`,
+			postID,
+		).Scan(&multiple, &closed)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+		if closed {
+			return ErrPollClosed
+		}
+		if len(optionIDs) == 0 || (!multiple && len(optionIDs) > 1) {
+			return ErrInvalidVote
+		}
+
+		_, err = tx.ExecContext(ctx, `INSERT INTO poll_voters (post_id, user_id) VALUES ($1, $2)`, postID, userID)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				return ErrConflict
+			}
+			return err
+		}
+
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO poll_votes (post_id, user_id, option_id)
+			SELECT $1, $2, o.id FROM poll_options o WHERE o.post_id = $1 AND o.id = ANY($3)
+		`, postID, userID, pq.Array(optionIDs))
+		if err != nil {
+			return err
+		}
+
+		votes, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		// some of the options aren't part of the poll, or were repeated
+		if votes != int64(len(optionIDs)) {
+			return ErrInvalidVote
+		}
+
+		return nil
+	})
+}
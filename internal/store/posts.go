@@ -4,25 +4,71 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"fmt"
-	"reflect"
+	"slices"
+	"time"
 
 	"github.com/lib/pq"
+	"github.com/lunatictiol/go-based-social-media/internal/entities"
+	"github.com/lunatictiol/go-based-social-media/internal/markdown"
 )
 
 type Post struct {
-	Id        int64     `json:"id"`
-	Content   string    `json:"content"`
-	Title     string    `json:"title"`
-	UserId    int64     `json:"userId"`
-	Tags      []string  `json:"tags"`
-	CreatedAt string    `json:"created_at"`
-	UpdatedAt string    `json:"updated_at"`
-	Comments  []Comment `json:"comments"`
-	Version   int       `json:"version"`
-	User      User      `json:"user"`
+	Id      int64  `json:"id"`
+	Content string `json:"content"`
+	// ContentFormat is plain or markdown, ContentHTML is Content rendered to
+	// sanitized HTML in that format
+	ContentFormat string     `json:"content_format"`
+	ContentHTML   string     `json:"content_html"`
+	Title         string     `json:"title"`
+	UserId        int64      `json:"userId"`
+	Tags          []string   `json:"tags"`
+	CreatedAt     string     `json:"created_at"`
+	UpdatedAt     string     `json:"updated_at"`
+	Comments      []Comment  `json:"comments"`
+	Version       int        `json:"version"`
+	User          User       `json:"user"`
+	DeletedAt     *string    `json:"deleted_at,omitempty"`
+	Status        string     `json:"status"`
+	PublishAt     *time.Time `json:"publish_at,omitempty"`
+	Kind          string     `json:"kind"`
+	// RepostOf is the id of the shared post for reposts and quote posts
+	RepostOf    *int64 `json:"repost_of,omitempty"`
+	Original    *Post  `json:"original,omitempty"`
+	RepostCount int    `json:"repost_count"`
+	Visibility  string `json:"visibility"`
+	// Bookmarked reports whether the user reading the post bookmarked it
+	Bookmarked bool `json:"bookmarked"`
+	// Mentions holds the usernames of the users mentioned in the post
+	Mentions []string `json:"mentions,omitempty"`
+	// Entities are the mentions and hashtags found in Content
+	Entities []entities.Entity `json:"entities"`
+	Poll     *Poll             `json:"poll,omitempty"`
+	// Previews are the cards of the links in Content, added once they are
+	// unfurled in the background
+	Previews []LinkPreview `json:"previews,omitempty"`
+	// HiddenAt is set when a moderator hid the post, it is then only shown
+	// to its author and moderators
+	HiddenAt *string `json:"hidden_at,omitempty"`
 }
 
+const (
+	PostStatusDraft     = "draft"
+	PostStatusScheduled = "scheduled"
+	PostStatusPublished = "published"
+)
+
+const (
+	PostKindPost   = "post"
+	PostKindRepost = "repost"
+	PostKindQuote  = "quote"
+)
+
+const (
+	PostVisibilityPublic    = "public"
+	PostVisibilityFollowers = "followers"
+	PostVisibilityMentioned = "mentioned"
+)
+
 type PostMetaData struct {
 	Post         `json:"post"`
 	CommentCount int `json:"comment_count"`
@@ -31,38 +77,168 @@ type PostStore struct {
 	db *sql.DB
 }
 
+// PostTrashRetention is how long a soft deleted post stays in the trash
+// before it can no longer be restored and gets purged.
+const PostTrashRetention = time.Hour * 24 * 30
+
 func (s *PostStore) Create(ctx context.Context, post *Post) error {
-	query := `INSERT INTO posts (content, title,user_id,tags) 
-	VALUES ($1,$2,$3,$4) RETURNING id,created_at,updated_at
-	`
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
-	defer cancel()
-	err := s.db.QueryRowContext(
-		ctx,
-		query,
-		post.Content,
-		post.Title,
-		post.UserId,
-		pq.Array(post.Tags),
-	).Scan(
-		&post.Id,
-		&post.CreatedAt,
-		&post.UpdatedAt,
-	)
+	if post.Status == "" {
+		post.Status = PostStatusPublished
+	}
+	if post.Kind == "" {
+		post.Kind = PostKindPost
+	}
+	if post.Visibility == "" {
+		post.Visibility = PostVisibilityPublic
+	}
+	if post.ContentFormat == "" {
+		post.ContentFormat = markdown.FormatPlain
+	}
+	post.ContentHTML = markdown.Render(post.ContentFormat, post.Content)
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `INSERT INTO posts (content, title,user_id,tags,status,publish_at,kind,repost_of,visibility,content_format,content_html) 
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11) RETURNING id,created_at,updated_at
+		`
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+		err := tx.QueryRowContext(
+			ctx,
+			query,
+			post.Content,
+			post.Title,
+			post.UserId,
+			pq.Array(post.Tags),
+			post.Status,
+			post.PublishAt,
+			post.Kind,
+			post.RepostOf,
+			post.Visibility,
+			post.ContentFormat,
+			post.ContentHTML,
+		).Scan(
+			&post.Id,
+			&post.CreatedAt,
+			&post.UpdatedAt,
+		)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				return ErrConflict
+			}
+			return err
+		}
+
+		if err := s.createMentions(ctx, tx, post); err != nil {
+			return err
+		}
+
+		if post.Poll != nil {
+			if err := createPoll(ctx, tx, post); err != nil {
+				return err
+			}
+		}
+
+		return s.syncHashtags(ctx, tx, post)
+	})
+}
+
+// createMentions links the post to the users named in post.Mentions and
+// reloads post.Mentions with the usernames that exist.
+func (s *PostStore) createMentions(ctx context.Context, tx *sql.Tx, post *Post) error {
+	if len(post.Mentions) == 0 {
+		return nil
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO post_mentions (post_id, user_id)
+		SELECT $1, id FROM users WHERE username = ANY($2)
+		ON CONFLICT DO NOTHING
+	`, post.Id, pq.Array(post.Mentions))
 	if err != nil {
 		return err
 	}
-	return nil
+
+	return tx.QueryRowContext(ctx, `
+		SELECT ARRAY(SELECT u.username FROM post_mentions pm JOIN users u ON u.id = pm.user_id WHERE pm.post_id = $1)
+	`, post.Id).Scan(pq.Array(&post.Mentions))
+}
+
+// syncHashtags replaces the hashtags recorded for the post with the ones
+// parsed from its content.
+func (s *PostStore) syncHashtags(ctx context.Context, tx *sql.Tx, post *Post) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM post_hashtags WHERE post_id = $1`, post.Id)
+	if err != nil {
+		return err
+	}
+
+	hashtags := entities.Hashtags(post.Entities)
+	if len(hashtags) == 0 {
+		return nil
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO post_hashtags (post_id, tag)
+		SELECT $1, unnest($2::varchar[])
+	`, post.Id, pq.Array(hashtags))
+
+	return err
+}
+
+// CanView reports whether viewerID is allowed to see the post. Authors can
+// always see their own posts.
+func (s *PostStore) CanView(ctx context.Context, post *Post, viewerID int64) (bool, error) {
+	if post.UserId == viewerID {
+		return true, nil
+	}
+	if post.HiddenAt != nil {
+		return false, nil
+	}
+
+	query := `SELECT EXISTS (SELECT 1 FROM posts p WHERE p.id = $1 AND ` + visibleTo("p", "$2") + `)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var visible bool
+	if err := s.db.QueryRowContext(ctx, query, post.Id, viewerID).Scan(&visible); err != nil {
+		return false, err
+	}
+
+	return visible, nil
+}
+
+// visibleTo returns the SQL condition under which the post aliased as post
+// can be seen by the user id bound to the viewer placeholder. Hidden posts
+// and posts of suspended users are only visible to their author.
+func visibleTo(post, viewer string) string {
+	return `(
+		` + post + `.user_id = ` + viewer + ` OR
+		(` + post + `.hidden_at IS NULL AND ` + notSuspended(post+".user_id") + ` AND (
+			` + post + `.visibility = 'public' OR
+			(` + post + `.visibility = 'followers' AND EXISTS (
+				SELECT 1 FROM followers vf WHERE vf.user_id = ` + post + `.user_id AND vf.follower_id = ` + viewer + `
+			)) OR
+			(` + post + `.visibility = 'mentioned' AND EXISTS (
+				SELECT 1 FROM post_mentions vm WHERE vm.post_id = ` + post + `.id AND vm.user_id = ` + viewer + `
+			))
+		))
+	)`
 }
 
 func (s *PostStore) GetPostByID(ctx context.Context, id int64) (*Post, error) {
 	var post Post
+	var previews []byte
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 	query := `
-		SELECT id, user_id, title, content, created_at,  updated_at, tags, version
+		SELECT id, user_id, title, content, content_format, content_html, created_at,  updated_at, tags, version, status, publish_at, kind, repost_of,
+			(SELECT COUNT(*) FROM posts r WHERE r.repost_of = posts.id AND r.kind = 'repost' AND r.deleted_at IS NULL),
+			visibility,
+			ARRAY(SELECT u.username FROM post_mentions pm JOIN users u ON u.id = pm.user_id WHERE pm.post_id = posts.id),
+			` + postPreviews("posts") + `,
+			hidden_at
 		FROM posts
-		WHERE id = $1
+		WHERE id = $1 AND deleted_at IS NULL
 	`
 	err := s.db.QueryRowContext(
 		ctx,
@@ -73,10 +249,21 @@ func (s *PostStore) GetPostByID(ctx context.Context, id int64) (*Post, error) {
 		&post.UserId,
 		&post.Title,
 		&post.Content,
+		&post.ContentFormat,
+		&post.ContentHTML,
 		&post.CreatedAt,
 		&post.UpdatedAt,
 		pq.Array(&post.Tags),
 		&post.Version,
+		&post.Status,
+		&post.PublishAt,
+		&post.Kind,
+		&post.RepostOf,
+		&post.RepostCount,
+		&post.Visibility,
+		pq.Array(&post.Mentions),
+		&previews,
+		&post.HiddenAt,
 	)
 	if err != nil {
 		switch {
@@ -86,104 +273,596 @@ func (s *PostStore) GetPostByID(ctx context.Context, id int64) (*Post, error) {
 			return nil, err
 		}
 	}
+	post.Entities = entities.Parse(post.Content)
+	if post.Previews, err = parsePreviews(previews); err != nil {
+		return nil, err
+	}
 	return &post, nil
 }
 
-func (s *PostStore) DeletePostByID(ctx context.Context, id int64) error {
+// DeletePostByID moves the post to the trash and unpins it. deletedBy is
+// who deleted it, only posts deleted by their author can be restored.
+func (s *PostStore) DeletePostByID(ctx context.Context, id, deletedBy int64) error {
+
+	query := `
+	UPDATE posts SET deleted_at = NOW(), deleted_by = $2
+	WHERE id = $1 AND deleted_at IS NULL
+	`
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+		res, err := tx.ExecContext(
+			ctx,
+			query,
+			id,
+			deletedBy)
+
+		if err != nil {
+			return err
+		}
+
+		row, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if row == 0 {
+			return ErrNotFound
+		}
+
+		return unpinPost(ctx, tx, id)
+	})
+}
+
+func (s *PostStore) UpdatePost(ctx context.Context, post *Post) error {
+	query := `
+	UPDATE posts
+	SET title = $1, content = $2, version = version + 1,
+		created_at = CASE WHEN status <> 'published' AND $5 = 'published' THEN NOW() ELSE created_at END,
+		status = $5, publish_at = $6, visibility = $7, tags = $8, content_format = $9, content_html = $10
+	WHERE id = $3 AND version = $4 AND deleted_at IS NULL
+	RETURNING version, created_at
+`
+	post.ContentHTML = markdown.Render(post.ContentFormat, post.Content)
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		// a pinned post stops being pinned when its audience changes
+		_, err := tx.ExecContext(ctx, `
+			DELETE FROM pinned_posts
+			WHERE post_id = $1 AND EXISTS (SELECT 1 FROM posts WHERE id = $1 AND visibility <> $2)
+		`, post.Id, post.Visibility)
+		if err != nil {
+			return err
+		}
+
+		err = tx.QueryRowContext(
+			ctx,
+			query,
+			post.Title,
+			post.Content,
+			post.Id,
+			post.Version,
+			post.Status,
+			post.PublishAt,
+			post.Visibility,
+			pq.Array(post.Tags),
+			post.ContentFormat,
+			post.ContentHTML,
+		).Scan(&post.Version, &post.CreatedAt)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		// users no longer mentioned lose access to mentioned-only posts
+		_, err = tx.ExecContext(ctx, `
+			DELETE FROM post_mentions pm
+			USING users u
+			WHERE pm.post_id = $1 AND u.id = pm.user_id AND NOT (u.username = ANY(COALESCE($2::text[], '{}')))
+		`, post.Id, pq.Array(post.Mentions))
+		if err != nil {
+			return err
+		}
+		if err := s.createMentions(ctx, tx, post); err != nil {
+			return err
+		}
+
+		return s.syncHashtags(ctx, tx, post)
+	})
+}
+
+// GetUserFeed returns a page of the feed of user id in fq.Sort order. Pages
+// are keyed on (created_at, id) so posts published while paging don't
+// shift them. A page fetched with fq.Before is still returned in fq.Sort
+// order.
+func (S *PostStore) GetUserFeed(ctx context.Context, id int64, fq PaginatedFeedQuery) ([]PostMetaData, error) {
+	return queryFeed(ctx, S.db, id, fq, feedCandidates)
+}
+
+// feedCandidates picks the posts of the feed: the user's own, those of the
+// users they follow and the public ones carrying tags they follow.
+const feedCandidates = `
+	f.follower_id = $1 OR p.user_id = $1 OR
+	(p.visibility = 'public' AND ` + followsTag + `)
+`
 
-	query := ` DELETE FROM posts WHERE id = $1
+// followsTag is the SQL condition under which post p carries a tag user $1
+// follows. Follows are stored lowercase while posts keep their tags as
+// written, so they are compared lowercased.
+const followsTag = `EXISTS (
+	SELECT 1 FROM unnest(p.tags) AS t(tag)
+	WHERE lower(t.tag) IN (SELECT tf.tag FROM tag_follows tf WHERE tf.user_id = $1)
+)`
+
+// CountFeedSince counts the posts of the feed of user id newer than since,
+// up to limit.
+func (S *PostStore) CountFeedSince(ctx context.Context, id int64, since PostCursor, limit int) (int, error) {
+	query := feedCTE(feedCandidates, ">") + `
+		SELECT COUNT(*)
+		FROM (
+			SELECT 1 FROM feed f
+			LIMIT $2
+		) n
 	`
+
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
-	res, err := s.db.ExecContext(
-		ctx,
-		query,
-		id)
 
+	var count int
+	err := S.db.QueryRowContext(ctx, query, id, limit, "", pq.Array([]string(nil)), since.CreatedAt, since.Id).Scan(&count)
+
+	return count, err
+}
+
+// feedCTE selects the feed of the viewer $1 as feed, the posts matching
+// candidates filtered by the search $3 and tags $4. When the cursor $5, $6
+// is set only the posts on the cmp side of it are selected.
+func feedCTE(candidates string, cmp string) string {
+	// shown is the condition under which post, with original the post it
+	// shares, makes it into the feed
+	shown := func(post, original string) string {
+		return `
+			(` + post + `.kind <> 'repost' OR (` + original + `.deleted_at IS NULL AND ` + visibleTo(original, "$1") + `)) AND
+			` + postNotMuted(post, original, "$1") + ` AND
+			(
+				` + post + `.title ILIKE '%' || $3 || '%' OR ` + post + `.content ILIKE '%' || $3 || '%' OR
+				` + original + `.title ILIKE '%' || $3 || '%' OR ` + original + `.content ILIKE '%' || $3 || '%'
+			) AND
+			($4::varchar[] IS NULL OR array_length($4::varchar[], 1) = 0 OR ` + post + `.tags @> $4::varchar[] OR ` + original + `.tags @> $4::varchar[])`
+	}
+
+	// pure reposts share their original's content, the feed keeps only the
+	// most recent appearance of each piece of content. Candidates are bound
+	// by the cursor so pages don't sort the whole feed. Walking up from a
+	// cursor every newer appearance is in the window, walking down the
+	// content that appeared at or above the cursor was already shown and is
+	// left out.
+	var shownAbove string
+	if cmp == "<" {
+		shownAbove = ` AND ($5::timestamptz IS NULL OR NOT EXISTS (
+				SELECT 1
+				FROM posts p
+				LEFT JOIN followers f ON f.user_id = p.user_id AND f.follower_id = $1
+				LEFT JOIN posts po ON po.id = p.repost_of
+				WHERE
+					` + contentID("p") + ` = c.content_id AND
+					p.deleted_at IS NULL AND p.status = 'published' AND
+					(p.created_at, p.id) >= ($5::timestamptz, $6::bigint) AND
+					(` + candidates + `) AND
+					` + visibleTo("p", "$1") + ` AND` + shown("p", "po") + `
+			))`
+	}
+
+	return `
+		WITH candidates AS (
+			SELECT p.*, ` + contentID("p") + ` AS content_id
+			FROM posts p
+			LEFT JOIN followers f ON f.user_id = p.user_id AND f.follower_id = $1
+			WHERE 
+				p.deleted_at IS NULL AND p.status = 'published' AND
+				($5::timestamptz IS NULL OR (p.created_at, p.id) ` + cmp + ` ($5::timestamptz, $6::bigint)) AND
+				(` + candidates + `) AND
+				` + visibleTo("p", "$1") + `
+		),
+		feed AS (
+			SELECT DISTINCT ON (c.content_id) c.*
+			FROM candidates c
+			LEFT JOIN posts o ON o.id = c.repost_of
+			WHERE` + shown("c", "o") + shownAbove + `
+			ORDER BY c.content_id, c.created_at DESC
+		)`
+}
+
+// queryFeed runs the feed query for the posts matching candidates, a
+// predicate on the post p and the viewer's follow f of its author. Extra
+// arguments are numbered from $7.
+func queryFeed(ctx context.Context, db *sql.DB, viewerID int64, fq PaginatedFeedQuery, candidates string, args ...any) ([]PostMetaData, error) {
+	order, cmp := "DESC", "<"
+	if fq.Sort == "asc" {
+		order, cmp = "ASC", ">"
+	}
+
+	cursor := fq.After
+	if fq.Before != nil {
+		// walk away from the cursor the other way, the page is flipped back
+		// once read
+		cursor = fq.Before
+		if order == "DESC" {
+			order, cmp = "ASC", ">"
+		} else {
+			order, cmp = "DESC", "<"
+		}
+	}
+
+	var cursorTime *string
+	var cursorID *int64
+	if cursor != nil {
+		cursorTime, cursorID = &cursor.CreatedAt, &cursor.Id
+	}
+
+	query := feedCTE(candidates, cmp) + `
+		SELECT ` + postListColumns("f") + `
+		FROM feed f ` + postListJoins("f", "$1") + `
+		ORDER BY f.created_at ` + order + `, f.id ` + order + `
+		LIMIT $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	args = append([]any{viewerID, fq.Limit, fq.Search, pq.Array(fq.Tags), cursorTime, cursorID}, args...)
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	feed, err := scanPostList(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if fq.Before != nil {
+		slices.Reverse(feed)
+	}
+
+	return feed, nil
+}
+
+const (
+	AuthorFilterAll     = "all"
+	AuthorFilterPosts   = "posts"
+	AuthorFilterReposts = "reposts"
+)
+
+type AuthorPostsQuery struct {
+	Limit int `validate:"gte=1,lte=50"`
+	// Filter is one of all, posts for the author's own posts and quotes,
+	// or reposts for their reposts and quotes
+	Filter string `validate:"oneof=all posts reposts"`
+	After  *PostCursor
+}
+
+// GetByUser returns the published posts of userID that viewerID can see,
+// newest first.
+func (s *PostStore) GetByUser(ctx context.Context, viewerID, userID int64, aq AuthorPostsQuery) ([]PostMetaData, error) {
+	var kinds []string
+	switch aq.Filter {
+	case AuthorFilterPosts:
+		kinds = []string{PostKindPost, PostKindQuote}
+	case AuthorFilterReposts:
+		kinds = []string{PostKindRepost, PostKindQuote}
+	default:
+		kinds = []string{PostKindPost, PostKindRepost, PostKindQuote}
+	}
+
+	var afterTime *string
+	var afterID int64
+	if aq.After != nil {
+		afterTime, afterID = &aq.After.CreatedAt, aq.After.Id
+	}
+
+	query := `
+		SELECT ` + postListColumns("p") + `
+		FROM posts p ` + postListJoins("p", "$1") + `
+		WHERE
+			p.user_id = $2 AND p.deleted_at IS NULL AND p.status = 'published' AND
+			p.kind = ANY($3) AND
+			($4::timestamptz IS NULL OR (p.created_at, p.id) < ($4, $5)) AND
+			` + visibleTo("p", "$1") + `
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT $6
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, viewerID, userID, pq.Array(kinds), afterTime, afterID, aq.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPostList(rows)
+}
+
+// GetTrash returns the posts userID deleted that can still be restored.
+// Posts deleted by moderators aren't in their author's trash.
+func (s *PostStore) GetTrash(ctx context.Context, userID int64) ([]Post, error) {
+	query := `
+		SELECT id, user_id, title, content, content_format, content_html, created_at, updated_at, tags, version, deleted_at
+		FROM posts
+		WHERE user_id = $1 AND deleted_by = $1 AND deleted_at IS NOT NULL AND deleted_at > $2
+		ORDER BY deleted_at DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, time.Now().Add(-PostTrashRetention))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []Post{}
+	for rows.Next() {
+		var p Post
+		err := rows.Scan(
+			&p.Id,
+			&p.UserId,
+			&p.Title,
+			&p.Content,
+			&p.ContentFormat,
+			&p.ContentHTML,
+			&p.CreatedAt,
+			&p.UpdatedAt,
+			pq.Array(&p.Tags),
+			&p.Version,
+			&p.DeletedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		p.Entities = entities.Parse(p.Content)
+		posts = append(posts, p)
+	}
+
+	return posts, rows.Err()
+}
+
+// Restore takes a post userID deleted out of the trash.
+func (s *PostStore) Restore(ctx context.Context, postID, userID int64) error {
+	query := `
+		UPDATE posts SET deleted_at = NULL, deleted_by = NULL
+		WHERE id = $1 AND user_id = $2 AND deleted_by = $2 AND deleted_at IS NOT NULL AND deleted_at > $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, postID, userID, time.Now().Add(-PostTrashRetention))
 	if err != nil {
 		return err
 	}
 
-	row, err := res.RowsAffected()
+	rows, err := res.RowsAffected()
 	if err != nil {
 		return err
 	}
-	if row == 0 {
+	if rows == 0 {
 		return ErrNotFound
 	}
+
 	return nil
 }
 
-func (s *PostStore) UpdatePost(ctx context.Context, post *Post) error {
+// PurgeDeleted permanently removes posts that have been in the trash for
+// longer than PostTrashRetention. Their comments go with them through the
+// fk_post cascade, and so do the pure reposts of them. Quotes of them are
+// kept without the quoted post.
+func (s *PostStore) PurgeDeleted(ctx context.Context) (int64, error) {
+	repostsQuery := `
+		DELETE FROM posts
+		WHERE kind = 'repost' AND repost_of IN (SELECT id FROM posts WHERE deleted_at < $1)
+	`
+	query := `DELETE FROM posts WHERE deleted_at < $1`
+
+	var purged int64
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		before := time.Now().Add(-PostTrashRetention)
+		for _, q := range []string{repostsQuery, query} {
+			res, err := tx.ExecContext(ctx, q, before)
+			if err != nil {
+				return err
+			}
+			n, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			purged += n
+		}
+
+		return nil
+	})
+
+	return purged, err
+}
+
+func (s *PostStore) GetByStatus(ctx context.Context, userID int64, status string) ([]Post, error) {
 	query := `
-	UPDATE posts
-	SET title = $1, content = $2, version = version + 1
-	WHERE id = $3 AND version = $4
-	RETURNING version
-`
+		SELECT id, user_id, title, content, content_format, content_html, created_at, updated_at, tags, version, status, publish_at
+		FROM posts
+		WHERE user_id = $1 AND status = $2 AND deleted_at IS NULL
+		ORDER BY publish_at ASC NULLS LAST, updated_at DESC
+	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	err := s.db.QueryRowContext(
-		ctx,
-		query,
-		post.Title,
-		post.Content,
-		post.Id,
-		post.Version,
-	).Scan(&post.Version)
+	rows, err := s.db.QueryContext(ctx, query, userID, status)
 	if err != nil {
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			return ErrNotFound
-		default:
-			return err
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []Post{}
+	for rows.Next() {
+		var p Post
+		err := rows.Scan(
+			&p.Id,
+			&p.UserId,
+			&p.Title,
+			&p.Content,
+			&p.ContentFormat,
+			&p.ContentHTML,
+			&p.CreatedAt,
+			&p.UpdatedAt,
+			pq.Array(&p.Tags),
+			&p.Version,
+			&p.Status,
+			&p.PublishAt,
+		)
+		if err != nil {
+			return nil, err
 		}
+		p.Entities = entities.Parse(p.Content)
+		posts = append(posts, p)
 	}
 
-	return nil
+	return posts, rows.Err()
 }
-func (S *PostStore) GetUserFeed(ctx context.Context, id int64, fq PaginatedFeedQuery) ([]PostMetaData, error) {
-	fmt.Println("Running with: id =", id, "limit =", fq.Limit, "offset =", fq.Offset, "search =", fq.Search, "tags =", fq.Tags)
-	fmt.Println(reflect.TypeOf(fq.Tags))
+
+// PublishDue publishes up to limit scheduled posts whose publish_at has
+// passed. Rows are claimed with FOR UPDATE SKIP LOCKED so concurrent
+// publishers never pick up the same post.
+func (s *PostStore) PublishDue(ctx context.Context, limit int) ([]Post, error) {
+	query := `
+		UPDATE posts
+		SET status = 'published', created_at = publish_at, updated_at = NOW()
+		WHERE status = 'scheduled' AND id IN (
+			SELECT id FROM posts
+			WHERE status = 'scheduled' AND publish_at <= NOW() AND deleted_at IS NULL
+			ORDER BY publish_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, user_id, title, content, created_at, updated_at, tags, version, status, publish_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []Post{}
+	for rows.Next() {
+		var p Post
+		err := rows.Scan(
+			&p.Id,
+			&p.UserId,
+			&p.Title,
+			&p.Content,
+			&p.CreatedAt,
+			&p.UpdatedAt,
+			pq.Array(&p.Tags),
+			&p.Version,
+			&p.Status,
+			&p.PublishAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		p.Entities = entities.Parse(p.Content)
+		posts = append(posts, p)
+	}
+
+	return posts, rows.Err()
+}
+
+// GetListByIDs returns the listing rows for the given post ids that the
+// viewer can see, in no particular order.
+func (s *PostStore) GetListByIDs(ctx context.Context, viewerID int64, ids []int64) ([]PostMetaData, error) {
 	query := `
-		SELECT 
-			p.id, p.user_id, p.title, p.content, p.created_at, p.version, p.tags,
-			u.username,
-			COUNT(c.id) AS comments_count
+		SELECT ` + postListColumns("p") + `
+		FROM posts p ` + postListJoins("p", "$1") + `
+		WHERE
+			p.id = ANY($2) AND p.deleted_at IS NULL AND p.status = 'published' AND
+			` + visibleTo("p", "$1") + ` AND
+			` + postNotMuted("p", "lo", "$1") + `
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, viewerID, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPostList(rows)
+}
+
+// GetIndexable returns up to limit posts that belong in a public search
+// index, published public posts that aren't pure reposts, with an id
+// greater than afterID in id order.
+func (s *PostStore) GetIndexable(ctx context.Context, afterID int64, limit int) ([]Post, error) {
+	query := `
+		SELECT p.id, p.user_id, u.username, p.title, p.content, p.created_at, p.tags, p.status, p.visibility, p.kind
 		FROM posts p
-		LEFT JOIN comments c ON c.post_id = p.id
-		LEFT JOIN users u ON p.user_id = u.id
-		JOIN followers f ON f.user_id = p.user_id AND f.follower_id = $1
-		WHERE 
-			(f.follower_id = $1 OR p.user_id = $1) AND
-			(p.title ILIKE '%' || $4 || '%' OR p.content ILIKE '%' || $4 || '%') AND
-			($5::varchar[] IS NULL OR array_length($5::varchar[], 1) = 0 OR p.tags @> $5::varchar[])
-		GROUP BY p.id, u.username
-		ORDER BY p.created_at ` + fq.Sort + `
-		LIMIT $2 OFFSET $3
+		JOIN users u ON u.id = p.user_id
+		WHERE
+			p.id > $1 AND p.deleted_at IS NULL AND p.status = 'published' AND
+			p.visibility = 'public' AND p.kind <> 'repost' AND p.hidden_at IS NULL AND
+			` + notSuspended("p.user_id") + `
+		ORDER BY p.id
+		LIMIT $2
 	`
 
-	rows, err := S.db.QueryContext(ctx, query, id, fq.Limit, fq.Offset, fq.Search, pq.Array(fq.Tags))
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
 
+	rows, err := s.db.QueryContext(ctx, query, afterID, limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var feed []PostMetaData
+	posts := []Post{}
 	for rows.Next() {
-		var p PostMetaData
+		var p Post
 		err := rows.Scan(
-			&p.Post.Id, &p.Post.UserId, &p.Post.Title, &p.Post.Content, &p.Post.CreatedAt, &p.Post.Version, pq.Array(&p.Post.Tags),
-			&p.Post.User.Username, &p.CommentCount,
+			&p.Id,
+			&p.UserId,
+			&p.User.Username,
+			&p.Title,
+			&p.Content,
+			&p.CreatedAt,
+			pq.Array(&p.Tags),
+			&p.Status,
+			&p.Visibility,
+			&p.Kind,
 		)
 		if err != nil {
 			return nil, err
 		}
-		feed = append(feed, p)
+		p.User.Id = p.UserId
+		posts = append(posts, p)
 	}
-	return feed, nil
 
+	return posts, rows.Err()
 }
@@ -0,0 +1,131 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// LinkPreview is the card shown for a link in a post, built from the
+// OpenGraph and Twitter card metadata of the linked page.
+type LinkPreview struct {
+	URL         string `json:"url"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Image       string `json:"image,omitempty"`
+	SiteName    string `json:"site_name,omitempty"`
+	Type        string `json:"type,omitempty"`
+	// Failed marks urls that couldn't be unfurled, they get no card
+	Failed    bool      `json:"-"`
+	FetchedAt time.Time `json:"-"`
+}
+
+type LinkPreviewStore struct {
+	db *sql.DB
+}
+
+func (s *LinkPreviewStore) Get(ctx context.Context, url string) (*LinkPreview, error) {
+	query := `
+		SELECT url, title, description, image, site_name, type, failed, fetched_at
+		FROM link_previews
+		WHERE url = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var p LinkPreview
+	err := s.db.QueryRowContext(ctx, query, url).Scan(
+		&p.URL,
+		&p.Title,
+		&p.Description,
+		&p.Image,
+		&p.SiteName,
+		&p.Type,
+		&p.Failed,
+		&p.FetchedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &p, nil
+}
+
+// Save stores the preview of a url, replacing an older one.
+func (s *LinkPreviewStore) Save(ctx context.Context, p *LinkPreview) error {
+	query := `
+		INSERT INTO link_previews (url, title, description, image, site_name, type, failed, fetched_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+		ON CONFLICT (url) DO UPDATE SET
+			title = EXCLUDED.title, description = EXCLUDED.description, image = EXCLUDED.image,
+			site_name = EXCLUDED.site_name, type = EXCLUDED.type, failed = EXCLUDED.failed,
+			fetched_at = EXCLUDED.fetched_at
+		RETURNING fetched_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(
+		ctx,
+		query,
+		p.URL,
+		p.Title,
+		p.Description,
+		p.Image,
+		p.SiteName,
+		p.Type,
+		p.Failed,
+	).Scan(&p.FetchedAt)
+}
+
+// SetPostLinks replaces the links of a post with urls, in order.
+func (s *LinkPreviewStore) SetPostLinks(ctx context.Context, postID int64, urls []string) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM post_links WHERE post_id = $1`, postID); err != nil {
+			return err
+		}
+
+		for i, url := range urls {
+			_, err := tx.ExecContext(ctx, `INSERT INTO post_links (post_id, position, url) VALUES ($1, $2, $3)`, postID, i+1, url)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// postPreviews selects the preview cards of the post aliased as post as a
+// JSON array, to be read back with parsePreviews.
+func postPreviews(post string) string {
+	return `COALESCE((
+		SELECT json_agg(json_build_object(
+			'url', lp.url, 'title', lp.title, 'description', lp.description,
+			'image', lp.image, 'site_name', lp.site_name, 'type', lp.type
+		) ORDER BY pl.position)
+		FROM post_links pl
+		JOIN link_previews lp ON lp.url = pl.url
+		WHERE pl.post_id = ` + post + `.id AND NOT lp.failed
+	), '[]')`
+}
+
+func parsePreviews(data []byte) ([]LinkPreview, error) {
+	previews := []LinkPreview{}
+	if err := json.Unmarshal(data, &previews); err != nil {
+		return nil, err
+	}
+	return previews, nil
+}
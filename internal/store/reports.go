@@ -0,0 +1,377 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	ReportTargetPost    = "post"
+	ReportTargetComment = "comment"
+	ReportTargetUser    = "user"
+)
+
+const (
+	ModerationDismiss = "dismiss"
+	ModerationHide    = "hide"
+	ModerationDelete  = "delete"
+	ModerationWarn    = "warn"
+	ModerationSuspend = "suspend"
+)
+
+var ErrSelfReport = errors.New("you can't report yourself")
+
+type Report struct {
+	Id         int64  `json:"id"`
+	ReporterId int64  `json:"reporter_id"`
+	Reporter   string `json:"reporter"`
+	TargetType string `json:"target_type"`
+	TargetId   int64  `json:"target_id"`
+	// TargetUserId is the author of the reported post or comment, or the
+	// reported account
+	TargetUserId int64   `json:"target_user_id"`
+	Reason       string  `json:"reason"`
+	Details      string  `json:"details"`
+	CreatedAt    string  `json:"created_at"`
+	ResolvedAt   *string `json:"resolved_at,omitempty"`
+	// ActionId is the moderation action that resolved the report
+	ActionId *int64 `json:"action_id,omitempty"`
+}
+
+// ReportQueueItem is a reported target with all its open reports.
+type ReportQueueItem struct {
+	TargetType     string   `json:"target_type"`
+	TargetId       int64    `json:"target_id"`
+	TargetUserId   int64    `json:"target_user_id"`
+	TargetUsername string   `json:"target_username"`
+	Reports        int      `json:"reports"`
+	Reasons        []string `json:"reasons"`
+	FirstReportAt  string   `json:"first_report_at"`
+	LastReportAt   string   `json:"last_report_at"`
+	// PriorActions counts the actions other than dismissals already taken
+	// against the target user
+	PriorActions int `json:"prior_actions"`
+}
+
+type ReportQueueQuery struct {
+	TargetType string `validate:"omitempty,oneof=post comment user"`
+	Limit      int    `validate:"gte=1,lte=50"`
+	Offset     int    `validate:"gte=0"`
+}
+
+type ModerationAction struct {
+	Id           int64  `json:"id"`
+	ModeratorId  *int64 `json:"moderator_id"`
+	TargetType   string `json:"target_type"`
+	TargetId     int64  `json:"target_id"`
+	TargetUserId *int64 `json:"target_user_id"`
+	Action       string `json:"action"`
+	Note         string `json:"note"`
+	// SuspendedUntil ends the suspension of a suspend action, suspensions
+	// without it are permanent
+	SuspendedUntil *time.Time `json:"suspended_until,omitempty"`
+	// Reports is how many open reports the action resolved
+	Reports   int    `json:"reports"`
+	CreatedAt string `json:"created_at"`
+}
+
+type ReportStore struct {
+	db *sql.DB
+}
+
+// Create files a report on a post or comment the reporter can see, or on an
+// active account. ErrNotFound is returned for any other target and
+// ErrConflict when the reporter already has an open report on it.
+func (s *ReportStore) Create(ctx context.Context, r *Report) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, `
+		SELECT p.user_id FROM posts p
+		WHERE
+			$2 = 'post' AND p.id = $3 AND p.deleted_at IS NULL AND p.status = 'published' AND
+			`+visibleTo("p", "$1")+`
+		UNION ALL
+		SELECT c.user_id FROM comments c JOIN posts p ON p.id = c.post_id
+		WHERE
+			$2 = 'comment' AND c.id = $3 AND p.deleted_at IS NULL AND p.status = 'published' AND
+			`+visibleTo("p", "$1")+`
+		UNION ALL
+		SELECT u.id FROM users u
+		WHERE $2 = 'user' AND u.id = $3 AND u.is_active = true
+	`, r.ReporterId, r.TargetType, r.TargetId).Scan(&r.TargetUserId)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+	if r.TargetUserId == r.ReporterId {
+		return ErrSelfReport
+	}
+
+	err = s.db.QueryRowContext(ctx, `
+		INSERT INTO reports (reporter_id, target_type, target_id, target_user_id, reason, details)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, r.ReporterId, r.TargetType, r.TargetId, r.TargetUserId, r.Reason, r.Details).Scan(&r.Id, &r.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrConflict
+		}
+		return err
+	}
+
+	return nil
+}
+
+// GetQueue returns the targets with open reports, the most reported first
+// and, among those, the ones waiting the longest.
+func (s *ReportStore) GetQueue(ctx context.Context, rq ReportQueueQuery) ([]ReportQueueItem, error) {
+	query := `
+		SELECT
+			r.target_type, r.target_id, r.target_user_id, u.username,
+			COUNT(*), array_agg(DISTINCT r.reason), MIN(r.created_at), MAX(r.created_at),
+			(
+				SELECT COUNT(*) FROM moderation_actions ma
+				WHERE ma.target_user_id = r.target_user_id AND ma.action <> 'dismiss'
+			)
+		FROM reports r
+		JOIN users u ON u.id = r.target_user_id
+		WHERE r.resolved_at IS NULL AND ($1 = '' OR r.target_type = $1)
+		GROUP BY r.target_type, r.target_id, r.target_user_id, u.username
+		ORDER BY COUNT(*) DESC, MIN(r.created_at)
+		LIMIT $2 OFFSET $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, rq.TargetType, rq.Limit, rq.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []ReportQueueItem{}
+	for rows.Next() {
+		var i ReportQueueItem
+		err := rows.Scan(
+			&i.TargetType, &i.TargetId, &i.TargetUserId, &i.TargetUsername,
+			&i.Reports, pq.Array(&i.Reasons), &i.FirstReportAt, &i.LastReportAt,
+			&i.PriorActions,
+		)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+
+	return items, rows.Err()
+}
+
+// GetByTarget returns every report filed on the target, open or resolved,
+// newest first.
+func (s *ReportStore) GetByTarget(ctx context.Context, targetType string, targetID int64) ([]Report, error) {
+	query := `
+		SELECT
+			r.id, r.reporter_id, u.username, r.target_type, r.target_id, r.target_user_id,
+			r.reason, r.details, r.created_at, r.resolved_at, r.action_id
+		FROM reports r
+		JOIN users u ON u.id = r.reporter_id
+		WHERE r.target_type = $1 AND r.target_id = $2
+		ORDER BY r.created_at DESC, r.id DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, targetType, targetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []Report{}
+	for rows.Next() {
+		var r Report
+		err := rows.Scan(
+			&r.Id, &r.ReporterId, &r.Reporter, &r.TargetType, &r.TargetId, &r.TargetUserId,
+			&r.Reason, &r.Details, &r.CreatedAt, &r.ResolvedAt, &r.ActionId,
+		)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, r)
+	}
+
+	return reports, rows.Err()
+}
+
+// GetActions returns the moderation actions taken on the target, newest
+// first.
+func (s *ReportStore) GetActions(ctx context.Context, targetType string, targetID int64) ([]ModerationAction, error) {
+	query := `
+		SELECT ` + moderationActionColumns + `
+		FROM moderation_actions ma
+		LEFT JOIN user_suspensions us ON us.action_id = ma.id
+		WHERE ma.target_type = $1 AND ma.target_id = $2
+		ORDER BY ma.created_at DESC, ma.id DESC
+	`
+
+	return s.queryActions(ctx, query, targetType, targetID)
+}
+
+// GetWarnings returns the warnings moderators gave the user, newest first.
+func (s *ReportStore) GetWarnings(ctx context.Context, userID int64) ([]ModerationAction, error) {
+	query := `
+		SELECT ` + moderationActionColumns + `
+		FROM moderation_actions ma
+		LEFT JOIN user_suspensions us ON us.action_id = ma.id
+		WHERE ma.target_user_id = $1 AND ma.action = 'warn'
+		ORDER BY ma.created_at DESC, ma.id DESC
+	`
+
+	return s.queryActions(ctx, query, userID)
+}
+
+const moderationActionColumns = `
+	ma.id, ma.moderator_id, ma.target_type, ma.target_id, ma.target_user_id, ma.action, ma.note,
+	us.ends_at, ma.reports, ma.created_at`
+
+func (s *ReportStore) queryActions(ctx context.Context, query string, args ...any) ([]ModerationAction, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	actions := []ModerationAction{}
+	for rows.Next() {
+		var a ModerationAction
+		err := rows.Scan(
+			&a.Id, &a.ModeratorId, &a.TargetType, &a.TargetId, &a.TargetUserId,
+			&a.Action, &a.Note, &a.SuspendedUntil, &a.Reports, &a.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, a)
+	}
+
+	return actions, rows.Err()
+}
+
+// Resolve takes action a on its target, records it and resolves the open
+// reports on the target with it. Hidden posts and comments stay visible to
+// their authors, deleted posts are purged with the trash but can't be
+// restored by their authors, deleted comments are gone and suspensions last
+// until a.SuspendedUntil. ErrNotFound is returned when the target has no
+// open reports or no longer exists.
+func (s *ReportStore) Resolve(ctx context.Context, a *ModerationAction) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		rows, err := tx.QueryContext(ctx, `
+			SELECT target_user_id FROM reports
+			WHERE target_type = $1 AND target_id = $2 AND resolved_at IS NULL
+			FOR UPDATE
+		`, a.TargetType, a.TargetId)
+		if err != nil {
+			return err
+		}
+		a.Reports = 0
+		for rows.Next() {
+			var userID int64
+			if err := rows.Scan(&userID); err != nil {
+				rows.Close()
+				return err
+			}
+			a.TargetUserId = &userID
+			a.Reports++
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if a.Reports == 0 {
+			return ErrNotFound
+		}
+
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO moderation_actions (moderator_id, target_type, target_id, target_user_id, action, note, reports)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING id, created_at
+		`, a.ModeratorId, a.TargetType, a.TargetId, a.TargetUserId, a.Action, a.Note, a.Reports).Scan(&a.Id, &a.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		if err := s.apply(ctx, tx, a); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE reports SET resolved_at = NOW(), action_id = $3
+			WHERE target_type = $1 AND target_id = $2 AND resolved_at IS NULL
+		`, a.TargetType, a.TargetId, a.Id)
+		return err
+	})
+}
+
+// apply carries out the effect of a on its target.
+func (s *ReportStore) apply(ctx context.Context, tx *sql.Tx, a *ModerationAction) error {
+	var query string
+	args := []any{a.TargetId}
+	switch {
+	case a.Action == ModerationHide && a.TargetType == ReportTargetPost:
+		query = `UPDATE posts SET hidden_at = NOW() WHERE id = $1 AND deleted_at IS NULL`
+	case a.Action == ModerationHide && a.TargetType == ReportTargetComment:
+		query = `UPDATE comments SET hidden_at = NOW() WHERE id = $1`
+	case a.Action == ModerationDelete && a.TargetType == ReportTargetPost:
+		query = `UPDATE posts SET deleted_at = NOW(), deleted_by = $2 WHERE id = $1 AND deleted_at IS NULL`
+		args = append(args, a.ModeratorId)
+	case a.Action == ModerationDelete && a.TargetType == ReportTargetComment:
+		query = `DELETE FROM comments WHERE id = $1`
+	case a.Action == ModerationSuspend:
+		return createSuspension(ctx, tx, &Suspension{
+			UserId:    *a.TargetUserId,
+			Reason:    a.Note,
+			EndsAt:    a.SuspendedUntil,
+			CreatedBy: a.ModeratorId,
+			ActionId:  &a.Id,
+		})
+	default:
+		// dismissals and warnings are only recorded
+		return nil
+	}
+
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	if a.Action == ModerationDelete && a.TargetType == ReportTargetPost {
+		return unpinPost(ctx, tx, a.TargetId)
+	}
+
+	return nil
+}
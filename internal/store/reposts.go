@@ -0,0 +1,73 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lunatictiol/go-based-social-media/internal/entities"
+)
+
+// originalPost holds the nullable columns of a shared post joined into a
+// feed row.
+type originalPost struct {
+	id            sql.NullInt64
+	userID        sql.NullInt64
+	title         sql.NullString
+	content       sql.NullString
+	contentFormat sql.NullString
+	contentHTML   sql.NullString
+	createdAt     sql.NullString
+	tags          []string
+	visibility    sql.NullString
+	username      sql.NullString
+}
+
+func (o originalPost) toPost() *Post {
+	if !o.id.Valid {
+		return nil
+	}
+
+	return &Post{
+		Id:            o.id.Int64,
+		UserId:        o.userID.Int64,
+		Title:         o.title.String,
+		Content:       o.content.String,
+		ContentFormat: o.contentFormat.String,
+		ContentHTML:   o.contentHTML.String,
+		CreatedAt:     o.createdAt.String,
+		Tags:          o.tags,
+		Kind:          PostKindPost,
+		Visibility:    o.visibility.String,
+		Entities:      entities.Parse(o.content.String),
+		User: User{
+			Id:       o.userID.Int64,
+			Username: o.username.String,
+		},
+	}
+}
+
+// DeleteRepost removes the user's pure repost of postID. Reposts carry no
+// content of their own so they skip the trash.
+func (s *PostStore) DeleteRepost(ctx context.Context, userID, postID int64) (int64, error) {
+	query := `
+		DELETE FROM posts
+		WHERE user_id = $1 AND repost_of = $2 AND kind = 'repost' AND deleted_at IS NULL
+		RETURNING id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var id int64
+	err := s.db.QueryRowContext(ctx, query, userID, postID).Scan(&id)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return 0, ErrNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return id, nil
+}
@@ -0,0 +1,247 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type PostSearchQuery struct {
+	Query  string     `json:"q" validate:"required,max=500"`
+	Author string     `json:"author" validate:"max=100"`
+	Tag    string     `json:"tag" validate:"max=100"`
+	Since  *time.Time `json:"since"`
+	Until  *time.Time `json:"until"`
+	Limit  int        `json:"limit" validate:"gte=1,lte=50"`
+	Offset int        `json:"offset" validate:"gte=0"`
+}
+
+type PostSearchResult struct {
+	PostMetaData
+	Rank float64 `json:"rank"`
+	// Snippet is an HTML fragment of the content with the matched terms
+	// wrapped in <mark>, everything else is escaped
+	Snippet string `json:"snippet"`
+}
+
+func (sq PostSearchQuery) Parse(r *http.Request) (PostSearchQuery, error) {
+	qs := r.URL.Query()
+
+	sq.Query = strings.TrimSpace(qs.Get("q"))
+	sq.Author = qs.Get("author")
+	sq.Tag = strings.TrimPrefix(qs.Get("tag"), "#")
+
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return PostSearchQuery{}, err
+		}
+		sq.Limit = l
+	}
+	offset := qs.Get("offset")
+	if offset != "" {
+		o, err := strconv.Atoi(offset)
+		if err != nil {
+			return PostSearchQuery{}, err
+		}
+		sq.Offset = o
+	}
+	since := qs.Get("since")
+	if since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return PostSearchQuery{}, err
+		}
+		sq.Since = &t
+	}
+	until := qs.Get("until")
+	if until != "" {
+		t, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			return PostSearchQuery{}, err
+		}
+		sq.Until = &t
+	}
+
+	return sq, nil
+}
+
+type UserSearchResult struct {
+	Id          int64  `json:"id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name,omitempty"`
+	// Following is true when the searching user follows this account
+	Following bool `json:"following"`
+	// FollowedByFollowing counts the accounts the searching user follows
+	// that follow this account
+	FollowedByFollowing int     `json:"followed_by_following"`
+	Score               float64 `json:"score"`
+}
+
+type SearchStore struct {
+	db *sql.DB
+}
+
+// SearchPosts runs a full-text search over the posts the viewer can see.
+// The query uses websearch syntax: quoted phrases, OR and -excluded terms.
+func (s *SearchStore) SearchPosts(ctx context.Context, viewerID int64, sq PostSearchQuery) ([]PostSearchResult, error) {
+	query := `
+		WITH q AS (SELECT websearch_to_tsquery('english', $2) AS query)
+		SELECT ` + postListColumns("p") + `,
+			ts_rank(p.search_vector, q.query) AS rank,
+			ts_headline(
+				'english',
+				replace(replace(replace(p.content, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'),
+				q.query,
+				'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=30, MinWords=10'
+			) AS snippet
+		FROM posts p CROSS JOIN q ` + postListJoins("p", "$1") + `
+		WHERE
+			p.search_vector @@ q.query AND
+			p.deleted_at IS NULL AND p.status = 'published' AND p.kind <> 'repost' AND
+			` + visibleTo("p", "$1") + ` AND
+			` + postNotMuted("p", "lo", "$1") + ` AND
+			($3 = '' OR lu.username = $3) AND
+			($4 = '' OR p.tags && ARRAY[$4, lower($4)]::varchar[]) AND
+			($5::timestamptz IS NULL OR p.created_at >= $5) AND
+			($6::timestamptz IS NULL OR p.created_at < $6)
+		ORDER BY rank DESC, p.created_at DESC
+		LIMIT $7 OFFSET $8
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		query,
+		viewerID,
+		sq.Query,
+		sq.Author,
+		sq.Tag,
+		sq.Since,
+		sq.Until,
+		sq.Limit,
+		sq.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []PostSearchResult{}
+	for rows.Next() {
+		var res PostSearchResult
+		if err := scanPostListRow(rows, &res.PostMetaData, &res.Rank, &res.Snippet); err != nil {
+			return nil, err
+		}
+		results = append(results, res)
+	}
+
+	return results, rows.Err()
+}
+
+// SearchUsers finds active users who aren't suspended and whose username or
+// display name is similar to q, tolerating typos through trigram
+// similarity. Accounts the viewer follows, or that are followed by accounts
+// the viewer follows, rank higher.
+func (s *SearchStore) SearchUsers(ctx context.Context, viewerID int64, q string, limit int) ([]UserSearchResult, error) {
+	query := `
+		WITH matches AS (
+			SELECT
+				u.id, u.username, COALESCE(u.display_name, '') AS display_name,
+				GREATEST(similarity(u.username, $2), similarity(COALESCE(u.display_name, ''), $2)) AS similarity,
+				EXISTS (SELECT 1 FROM followers f WHERE f.user_id = u.id AND f.follower_id = $1) AS following,
+				(
+					SELECT COUNT(*) FROM followers f
+					JOIN followers mine ON mine.user_id = f.follower_id AND mine.follower_id = $1
+					WHERE f.user_id = u.id
+				) AS followed_by_following
+			FROM users u
+			WHERE
+				u.is_active = true AND u.id <> $1 AND ` + notSuspended("u.id") + ` AND
+				(
+					u.username % $2 OR u.display_name % $2 OR
+					u.username ILIKE $3 || '%' OR u.display_name ILIKE $3 || '%'
+				)
+		)
+		SELECT id, username, display_name, following, followed_by_following,
+			similarity
+				+ CASE WHEN following THEN 0.3 ELSE 0 END
+				+ LEAST(followed_by_following, 5) * 0.05 AS score
+		FROM matches
+		ORDER BY score DESC, username
+		LIMIT $4
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, viewerID, q, escapeLike(q), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []UserSearchResult{}
+	for rows.Next() {
+		var u UserSearchResult
+		err := rows.Scan(&u.Id, &u.Username, &u.DisplayName, &u.Following, &u.FollowedByFollowing, &u.Score)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// GetUserResults builds search results for the given user ids, in the same
+// order, with the follow signals relative to the viewer filled in. Ids of
+// users that are no longer active are skipped.
+func (s *SearchStore) GetUserResults(ctx context.Context, viewerID int64, ids []int64) ([]UserSearchResult, error) {
+	query := `
+		SELECT
+			u.id, u.username, COALESCE(u.display_name, ''),
+			EXISTS (SELECT 1 FROM followers f WHERE f.user_id = u.id AND f.follower_id = $1),
+			(
+				SELECT COUNT(*) FROM followers f
+				JOIN followers mine ON mine.user_id = f.follower_id AND mine.follower_id = $1
+				WHERE f.user_id = u.id
+			)
+		FROM users u
+		WHERE u.id = ANY($2) AND u.is_active = true
+		ORDER BY array_position($2, u.id)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, viewerID, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []UserSearchResult{}
+	for rows.Next() {
+		var u UserSearchResult
+		err := rows.Scan(&u.Id, &u.Username, &u.DisplayName, &u.Following, &u.FollowedByFollowing)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
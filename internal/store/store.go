@@ -17,21 +17,41 @@ type Storage struct {
 	Posts interface {
 		Create(context.Context, *Post) error
 		GetPostByID(ctx context.Context, id int64) (*Post, error)
-		DeletePostByID(ctx context.Context, id int64) error
+		DeletePostByID(ctx context.Context, id, deletedBy int64) error
 		UpdatePost(ctx context.Context, post *Post) error
 		GetUserFeed(ctx context.Context, id int64, fq PaginatedFeedQuery) ([]PostMetaData, error)
+		CountFeedSince(ctx context.Context, id int64, since PostCursor, limit int) (int, error)
+		GetByUser(ctx context.Context, viewerID, userID int64, aq AuthorPostsQuery) ([]PostMetaData, error)
+		GetTrash(ctx context.Context, userID int64) ([]Post, error)
+		Restore(ctx context.Context, postID, userID int64) error
+		PurgeDeleted(ctx context.Context) (int64, error)
+		GetByStatus(ctx context.Context, userID int64, status string) ([]Post, error)
+		PublishDue(ctx context.Context, limit int) ([]Post, error)
+		DeleteRepost(ctx context.Context, userID, postID int64) (int64, error)
+		CanView(ctx context.Context, post *Post, viewerID int64) (bool, error)
+		GetListByIDs(ctx context.Context, viewerID int64, ids []int64) ([]PostMetaData, error)
+		GetIndexable(ctx context.Context, afterID int64, limit int) ([]Post, error)
+		GetFeedCandidates(ctx context.Context, viewerID int64, cq CandidateQuery) ([]FeedCandidate, error)
+		GetPublicRecent(ctx context.Context, limit int) ([]PostMetaData, error)
+		GetPublicPopular(ctx context.Context, since time.Time, limit int) ([]PostMetaData, error)
 	}
 	Users interface {
 		Create(context.Context, *User, *sql.Tx) error
 		GetUserByID(ctx context.Context, id int64) (*User, error)
 		CreateAndInvite(ctx context.Context, user *User, token string, exp time.Duration) error
-		Activate(context.Context, string) error
+		Activate(context.Context, string) (*User, error)
 		Delete(ctx context.Context, userID int64) error
 		GetByEmail(ctx context.Context, email string) (*User, error)
+		GetActive(ctx context.Context, afterID int64, limit int) ([]User, error)
+		List(ctx context.Context, uq UserListQuery) ([]User, error)
+		GetAccount(ctx context.Context, id int64) (*User, error)
+		SetRole(ctx context.Context, userID int64, role *Role) error
+		SetActive(ctx context.Context, userID int64, active bool) error
+		RevokeTokens(ctx context.Context, userID int64) error
 	}
 	Comments interface {
 		Create(context.Context, *Comment) error
-		GetByPostID(ctx context.Context, postID int64) ([]Comment, error)
+		GetByPostID(ctx context.Context, postID, viewerID int64) ([]Comment, error)
 	}
 	Followers interface {
 		Follow(ctx context.Context, userId, followerId int64) error
@@ -40,15 +60,104 @@ type Storage struct {
 	Roles interface {
 		GetByName(context.Context, string) (*Role, error)
 	}
+	Tags interface {
+		GetPosts(ctx context.Context, tag string, viewerID int64, fq PaginatedFeedQuery) ([]PostMetaData, error)
+		Trending(ctx context.Context, window, baseline time.Duration, limit int) ([]TrendingTag, error)
+		Follow(ctx context.Context, userID int64, tag string) error
+		Unfollow(ctx context.Context, userID int64, tag string) error
+		GetFollowed(ctx context.Context, userID int64) ([]TagFollow, error)
+	}
+	Search interface {
+		SearchPosts(ctx context.Context, viewerID int64, sq PostSearchQuery) ([]PostSearchResult, error)
+		SearchUsers(ctx context.Context, viewerID int64, q string, limit int) ([]UserSearchResult, error)
+		GetUserResults(ctx context.Context, viewerID int64, ids []int64) ([]UserSearchResult, error)
+	}
+	Bookmarks interface {
+		Add(ctx context.Context, userID, postID int64, collectionID *int64) error
+		Remove(ctx context.Context, userID, postID int64) error
+		IsBookmarked(ctx context.Context, userID, postID int64) (bool, error)
+		Get(ctx context.Context, viewerID int64, bq BookmarkQuery) ([]Bookmark, error)
+		CreateCollection(ctx context.Context, c *BookmarkCollection) error
+		GetCollections(ctx context.Context, userID int64) ([]BookmarkCollection, error)
+		GetCollection(ctx context.Context, id int64) (*BookmarkCollection, error)
+		UpdateCollection(ctx context.Context, c *BookmarkCollection) error
+		DeleteCollection(ctx context.Context, userID, id int64) error
+	}
+	Pins interface {
+		Pin(ctx context.Context, userID, postID int64, position int) error
+		Unpin(ctx context.Context, userID, postID int64) error
+		GetPinned(ctx context.Context, viewerID, userID int64) ([]PostMetaData, error)
+	}
+	Polls interface {
+		GetByPostID(ctx context.Context, postID, viewerID int64) (*Poll, error)
+		Vote(ctx context.Context, postID, userID int64, optionIDs []int64) error
+	}
+	LinkPreviews interface {
+		Get(ctx context.Context, url string) (*LinkPreview, error)
+		Save(ctx context.Context, p *LinkPreview) error
+		SetPostLinks(ctx context.Context, postID int64, urls []string) error
+	}
+	MutedWords interface {
+		Create(ctx context.Context, w *MutedWord) error
+		GetByUser(ctx context.Context, userID int64) ([]MutedWord, error)
+		Delete(ctx context.Context, userID, id int64) error
+	}
+	Reports interface {
+		Create(ctx context.Context, r *Report) error
+		GetQueue(ctx context.Context, rq ReportQueueQuery) ([]ReportQueueItem, error)
+		GetByTarget(ctx context.Context, targetType string, targetID int64) ([]Report, error)
+		GetActions(ctx context.Context, targetType string, targetID int64) ([]ModerationAction, error)
+		GetWarnings(ctx context.Context, userID int64) ([]ModerationAction, error)
+		Resolve(ctx context.Context, a *ModerationAction) error
+	}
+	Suspensions interface {
+		Create(ctx context.Context, sp *Suspension) error
+		GetActive(ctx context.Context, userID int64) (*Suspension, error)
+		GetByUser(ctx context.Context, userID int64) ([]Suspension, error)
+		Lift(ctx context.Context, userID, liftedBy int64) error
+		CreateAppeal(ctx context.Context, userID int64, appeal *Appeal) error
+		GetAppeals(ctx context.Context, status string, limit, offset int) ([]Appeal, error)
+		GetAppeal(ctx context.Context, id int64) (*Appeal, error)
+		DecideAppeal(ctx context.Context, appeal *Appeal, decidedBy int64) error
+	}
+	Audit interface {
+		Create(ctx context.Context, e *AuditEvent) error
+		Get(ctx context.Context, aq AuditQuery) ([]AuditEvent, error)
+	}
+	FeedMarkers interface {
+		Get(ctx context.Context, userID int64) (*FeedMarker, error)
+		Set(ctx context.Context, m *FeedMarker) error
+	}
+	Timelines interface {
+		GetFollowerIDs(ctx context.Context, userID int64, limit int) ([]int64, error)
+		IsPopular(ctx context.Context, userID int64, maxFollowers int) (bool, error)
+		GetPopularFollowed(ctx context.Context, userID int64, maxFollowers int) ([]int64, error)
+		GetHome(ctx context.Context, userID int64, limit int) ([]TimelineEntry, error)
+		GetByAuthor(ctx context.Context, authorID int64, limit int) ([]TimelineEntry, error)
+		GetPulled(ctx context.Context, viewerID int64, authorIDs []int64, fq PaginatedFeedQuery) ([]PostMetaData, error)
+		GetShownAbove(ctx context.Context, viewerID int64, cursor PostCursor, contentIDs []int64) ([]int64, error)
+	}
 }
 
 func NewStorage(db *sql.DB) Storage {
 	return Storage{
-		Posts:     &PostStore{db: db},
-		Users:     &UserStore{db: db},
-		Comments:  &CommentStore{db: db},
-		Followers: &FollowStore{db: db},
-		Roles:     &RoleStore{db: db},
+		Posts:        &PostStore{db: db},
+		Users:        &UserStore{db: db},
+		Comments:     &CommentStore{db: db},
+		Followers:    &FollowStore{db: db},
+		Roles:        &RoleStore{db: db},
+		Tags:         &TagStore{db: db},
+		Search:       &SearchStore{db: db},
+		Bookmarks:    &BookmarkStore{db: db},
+		Pins:         &PinStore{db: db},
+		Polls:        &PollStore{db: db},
+		LinkPreviews: &LinkPreviewStore{db: db},
+		MutedWords:   &MutedWordStore{db: db},
+		Reports:      &ReportStore{db: db},
+		Suspensions:  &SuspensionStore{db: db},
+		Audit:        &AuditStore{db: db},
+		FeedMarkers:  &FeedMarkerStore{db: db},
+		Timelines:    &TimelineStore{db: db},
 	}
 }
 
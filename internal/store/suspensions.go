@@ -0,0 +1,329 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	AppealPending  = "pending"
+	AppealAccepted = "accepted"
+	AppealRejected = "rejected"
+)
+
+// Suspension stops a user from signing in and using the API, and hides their
+// posts and comments from everyone else, until EndsAt or, when EndsAt is
+// nil, until it is lifted.
+type Suspension struct {
+	Id        int64      `json:"id"`
+	UserId    int64      `json:"user_id"`
+	Reason    string     `json:"reason"`
+	EndsAt    *time.Time `json:"ends_at"`
+	CreatedBy *int64     `json:"created_by,omitempty"`
+	// ActionId is the moderation action the user was suspended by, when
+	// they were suspended over reports
+	ActionId  *int64  `json:"action_id,omitempty"`
+	CreatedAt string  `json:"created_at"`
+	LiftedAt  *string `json:"lifted_at,omitempty"`
+}
+
+type Appeal struct {
+	Id           int64       `json:"id"`
+	SuspensionId int64       `json:"suspension_id"`
+	Suspension   *Suspension `json:"suspension,omitempty"`
+	Message      string      `json:"message"`
+	Status       string      `json:"status"`
+	// Response is the moderator's answer to the appeal
+	Response  string  `json:"response"`
+	CreatedAt string  `json:"created_at"`
+	DecidedAt *string `json:"decided_at,omitempty"`
+}
+
+type SuspensionStore struct {
+	db *sql.DB
+}
+
+// notSuspended returns the SQL condition under which the user id user, a
+// column or placeholder, has no suspension in force.
+func notSuspended(user string) string {
+	return `NOT EXISTS (
+		SELECT 1 FROM user_suspensions us
+		WHERE
+			us.user_id = ` + user + ` AND us.lifted_at IS NULL AND
+			(us.ends_at IS NULL OR us.ends_at > NOW())
+	)`
+}
+
+func (s *SuspensionStore) Create(ctx context.Context, sp *Suspension) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		return createSuspension(ctx, tx, sp)
+	})
+}
+
+func createSuspension(ctx context.Context, tx *sql.Tx, sp *Suspension) error {
+	query := `
+		INSERT INTO user_suspensions (user_id, reason, ends_at, created_by, action_id)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+
+	err := tx.QueryRowContext(ctx, query, sp.UserId, sp.Reason, sp.EndsAt, sp.CreatedBy, sp.ActionId).Scan(&sp.Id, &sp.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return ErrNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+// GetActive returns the user's suspension in force, the one ending last
+// when there are several. ErrNotFound is returned when there is none.
+func (s *SuspensionStore) GetActive(ctx context.Context, userID int64) (*Suspension, error) {
+	query := `
+		SELECT ` + suspensionColumns + `
+		FROM user_suspensions
+		WHERE user_id = $1 AND lifted_at IS NULL AND (ends_at IS NULL OR ends_at > NOW())
+		ORDER BY ends_at DESC NULLS FIRST
+		LIMIT 1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return scanSuspension(s.db.QueryRowContext(ctx, query, userID))
+}
+
+// GetByUser returns every suspension of the user, newest first.
+func (s *SuspensionStore) GetByUser(ctx context.Context, userID int64) ([]Suspension, error) {
+	query := `
+		SELECT ` + suspensionColumns + `
+		FROM user_suspensions
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suspensions := []Suspension{}
+	for rows.Next() {
+		var sp Suspension
+		err := rows.Scan(&sp.Id, &sp.UserId, &sp.Reason, &sp.EndsAt, &sp.CreatedBy, &sp.ActionId, &sp.CreatedAt, &sp.LiftedAt)
+		if err != nil {
+			return nil, err
+		}
+		suspensions = append(suspensions, sp)
+	}
+
+	return suspensions, rows.Err()
+}
+
+// Lift ends the user's suspensions in force. ErrNotFound is returned when
+// there are none.
+func (s *SuspensionStore) Lift(ctx context.Context, userID, liftedBy int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		return liftSuspensions(ctx, tx, `user_id = $1`, userID, liftedBy)
+	})
+}
+
+// liftSuspensions lifts the suspensions in force matching where, a condition
+// on the id bound to $1.
+func liftSuspensions(ctx context.Context, tx *sql.Tx, where string, id, liftedBy int64) error {
+	query := `
+		UPDATE user_suspensions SET lifted_at = NOW(), lifted_by = $2
+		WHERE ` + where + ` AND lifted_at IS NULL AND (ends_at IS NULL OR ends_at > NOW())
+	`
+
+	res, err := tx.ExecContext(ctx, query, id, liftedBy)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// CreateAppeal appeals the suspension in force of userID. ErrNotFound is
+// returned when the user isn't suspended and ErrConflict when the
+// suspension was already appealed.
+func (s *SuspensionStore) CreateAppeal(ctx context.Context, userID int64, appeal *Appeal) error {
+	query := `
+		INSERT INTO suspension_appeals (suspension_id, message)
+		VALUES ($1, $2)
+		RETURNING id, status, response, created_at
+	`
+
+	suspension, err := s.GetActive(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	appeal.SuspensionId = suspension.Id
+	appeal.Suspension = suspension
+	err = s.db.QueryRowContext(ctx, query, suspension.Id, appeal.Message).
+		Scan(&appeal.Id, &appeal.Status, &appeal.Response, &appeal.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrConflict
+		}
+		return err
+	}
+
+	return nil
+}
+
+// GetAppeals returns the appeals with the given status and their
+// suspensions, oldest first.
+func (s *SuspensionStore) GetAppeals(ctx context.Context, status string, limit, offset int) ([]Appeal, error) {
+	query := `
+		SELECT ` + appealColumns + `
+		FROM suspension_appeals a
+		JOIN user_suspensions s ON s.id = a.suspension_id
+		WHERE a.status = $1
+		ORDER BY a.created_at, a.id
+		LIMIT $2 OFFSET $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	appeals := []Appeal{}
+	for rows.Next() {
+		a := Appeal{Suspension: &Suspension{}}
+		sp := a.Suspension
+		err := rows.Scan(
+			&a.Id, &a.SuspensionId, &a.Message, &a.Status, &a.Response, &a.CreatedAt, &a.DecidedAt,
+			&sp.Id, &sp.UserId, &sp.Reason, &sp.EndsAt, &sp.CreatedBy, &sp.ActionId, &sp.CreatedAt, &sp.LiftedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		appeals = append(appeals, a)
+	}
+
+	return appeals, rows.Err()
+}
+
+// GetAppeal returns the appeal id with its suspension.
+func (s *SuspensionStore) GetAppeal(ctx context.Context, id int64) (*Appeal, error) {
+	query := `
+		SELECT ` + appealColumns + `
+		FROM suspension_appeals a
+		JOIN user_suspensions s ON s.id = a.suspension_id
+		WHERE a.id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	a := Appeal{Suspension: &Suspension{}}
+	sp := a.Suspension
+	err := s.db.QueryRowContext(ctx, query, id).Scan(
+		&a.Id, &a.SuspensionId, &a.Message, &a.Status, &a.Response, &a.CreatedAt, &a.DecidedAt,
+		&sp.Id, &sp.UserId, &sp.Reason, &sp.EndsAt, &sp.CreatedBy, &sp.ActionId, &sp.CreatedAt, &sp.LiftedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &a, nil
+}
+
+// DecideAppeal accepts or rejects a pending appeal with a response to the
+// user. Accepting it lifts the suspension. ErrNotFound is returned when the
+// appeal doesn't exist or was already decided.
+func (s *SuspensionStore) DecideAppeal(ctx context.Context, appeal *Appeal, decidedBy int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, `
+			UPDATE suspension_appeals
+			SET status = $2, response = $3, decided_at = NOW(), decided_by = $4
+			WHERE id = $1 AND status = 'pending'
+			RETURNING suspension_id, message, created_at, decided_at
+		`, appeal.Id, appeal.Status, appeal.Response, decidedBy).
+			Scan(&appeal.SuspensionId, &appeal.Message, &appeal.CreatedAt, &appeal.DecidedAt)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		if appeal.Status != AppealAccepted {
+			return nil
+		}
+
+		err = liftSuspensions(ctx, tx, `id = $1`, appeal.SuspensionId, decidedBy)
+		if errors.Is(err, ErrNotFound) {
+			// the suspension ended or was lifted in the meantime
+			return nil
+		}
+		return err
+	})
+}
+
+const suspensionColumns = `id, user_id, reason, ends_at, created_by, action_id, created_at, lifted_at`
+
+const appealColumns = `
+	a.id, a.suspension_id, a.message, a.status, a.response, a.created_at, a.decided_at,
+	s.id, s.user_id, s.reason, s.ends_at, s.created_by, s.action_id, s.created_at, s.lifted_at`
+
+func scanSuspension(row *sql.Row) (*Suspension, error) {
+	var sp Suspension
+	err := row.Scan(&sp.Id, &sp.UserId, &sp.Reason, &sp.EndsAt, &sp.CreatedBy, &sp.ActionId, &sp.CreatedAt, &sp.LiftedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &sp, nil
+}
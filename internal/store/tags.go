@@ -0,0 +1,165 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type TrendingTag struct {
+	Tag string `json:"tag"`
+	// Uses is how many posts used the tag inside the trending window
+	Uses int `json:"uses"`
+	// Velocity compares the hourly usage inside the window with the hourly
+	// usage over the rest of the baseline period
+	Velocity float64 `json:"velocity"`
+}
+
+type TagFollow struct {
+	Tag       string `json:"tag"`
+	CreatedAt string `json:"created_at"`
+}
+
+type TagStore struct {
+	db *sql.DB
+}
+
+// GetPosts returns the public timeline of a tag, newest first. Pure reposts
+// are left out so every post shows up once. tag must be lowercase, tags
+// given apart from the content kept their case and are matched
+// case-insensitively. viewerID is 0 for anonymous viewers.
+func (s *TagStore) GetPosts(ctx context.Context, tag string, viewerID int64, fq PaginatedFeedQuery) ([]PostMetaData, error) {
+	query := `
+		SELECT ` + postListColumns("p") + `
+		FROM posts p ` + postListJoins("p", "$1") + `
+		WHERE
+			p.deleted_at IS NULL AND p.status = 'published' AND p.visibility = 'public' AND
+			p.hidden_at IS NULL AND ` + notSuspended("p.user_id") + ` AND p.kind <> 'repost' AND
+			EXISTS (SELECT 1 FROM unnest(p.tags) AS t(tag) WHERE lower(t.tag) = $2)
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT $3 OFFSET $4
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, viewerID, tag, fq.Limit, fq.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPostList(rows)
+}
+
+// Trending ranks the tags used by public posts inside window by how much
+// faster they are being used than over the baseline period before it.
+func (s *TagStore) Trending(ctx context.Context, window, baseline time.Duration, limit int) ([]TrendingTag, error) {
+	query := `
+		WITH usage AS (
+			SELECT lower(t.tag) AS tag, p.created_at
+			FROM posts p, unnest(p.tags) AS t(tag)
+			WHERE
+				p.deleted_at IS NULL AND p.status = 'published' AND p.visibility = 'public' AND
+				p.hidden_at IS NULL AND ` + notSuspended("p.user_id") + ` AND p.kind <> 'repost' AND p.created_at > NOW() - make_interval(secs => $2)
+		),
+		counts AS (
+			SELECT
+				tag,
+				COUNT(*) FILTER (WHERE created_at > NOW() - make_interval(secs => $1)) AS recent,
+				COUNT(*) FILTER (WHERE created_at <= NOW() - make_interval(secs => $1)) AS older
+			FROM usage
+			GROUP BY tag
+		)
+		SELECT tag, recent,
+			(recent / ($1 / 3600.0)) / (older / (($2 - $1) / 3600.0) + 1) AS velocity
+		FROM counts
+		WHERE recent > 0
+		ORDER BY velocity DESC, recent DESC, tag
+		LIMIT $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, window.Seconds(), baseline.Seconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []TrendingTag{}
+	for rows.Next() {
+		var t TrendingTag
+		if err := rows.Scan(&t.Tag, &t.Uses, &t.Velocity); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+
+	return tags, rows.Err()
+}
+
+func (s *TagStore) Follow(ctx context.Context, userID int64, tag string) error {
+	query := `INSERT INTO tag_follows (user_id, tag) VALUES ($1, $2)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, tag)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrConflict
+		}
+	}
+
+	return err
+}
+
+func (s *TagStore) Unfollow(ctx context.Context, userID int64, tag string) error {
+	query := `DELETE FROM tag_follows WHERE user_id = $1 AND tag = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, tag)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *TagStore) GetFollowed(ctx context.Context, userID int64) ([]TagFollow, error) {
+	query := `SELECT tag, created_at FROM tag_follows WHERE user_id = $1 ORDER BY tag`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	follows := []TagFollow{}
+	for rows.Next() {
+		var f TagFollow
+		if err := rows.Scan(&f.Tag, &f.CreatedAt); err != nil {
+			return nil, err
+		}
+		follows = append(follows, f)
+	}
+
+	return follows, rows.Err()
+}
@@ -0,0 +1,184 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// TimelineEntry is a post in a precomputed home timeline.
+type TimelineEntry struct {
+	PostId    int64
+	CreatedAt time.Time
+}
+
+// TimelineStore answers the queries behind home timelines kept outside of
+// Postgres: who a post is fanned out to, what a timeline is rebuilt from
+// and the posts of popular authors that are pulled when a timeline is read
+// instead of being fanned out.
+type TimelineStore struct {
+	db *sql.DB
+}
+
+// GetFollowerIDs returns the ids of up to limit followers of userID.
+func (s *TimelineStore) GetFollowerIDs(ctx context.Context, userID int64, limit int) ([]int64, error) {
+	query := `SELECT follower_id FROM followers WHERE user_id = $1 LIMIT $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// IsPopular reports whether userID has more than maxFollowers followers.
+func (s *TimelineStore) IsPopular(ctx context.Context, userID int64, maxFollowers int) (bool, error) {
+	query := `SELECT COUNT(*) > $2 FROM (SELECT 1 FROM followers WHERE user_id = $1 LIMIT $2 + 1) f`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var popular bool
+	err := s.db.QueryRowContext(ctx, query, userID, maxFollowers).Scan(&popular)
+
+	return popular, err
+}
+
+// GetPopularFollowed returns the ids of the users userID follows that have
+// more than maxFollowers followers.
+func (s *TimelineStore) GetPopularFollowed(ctx context.Context, userID int64, maxFollowers int) ([]int64, error) {
+	query := `
+		SELECT f.user_id
+		FROM followers f
+		WHERE
+			f.follower_id = $1 AND
+			(SELECT COUNT(*) FROM (SELECT 1 FROM followers c WHERE c.user_id = f.user_id LIMIT $2 + 1) x) > $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, maxFollowers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// GetHome returns the latest limit published posts of userID and the users
+// they follow, newest first, to rebuild their timeline from.
+func (s *TimelineStore) GetHome(ctx context.Context, userID int64, limit int) ([]TimelineEntry, error) {
+	query := `
+		SELECT p.id, p.created_at
+		FROM posts p
+		WHERE
+			p.deleted_at IS NULL AND p.status = 'published' AND
+			(p.user_id = $1 OR p.user_id IN (SELECT f.user_id FROM followers f WHERE f.follower_id = $1))
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT $2
+	`
+
+	return s.getEntries(ctx, query, userID, limit)
+}
+
+// GetByAuthor returns the latest limit published posts of authorID, newest
+// first.
+func (s *TimelineStore) GetByAuthor(ctx context.Context, authorID int64, limit int) ([]TimelineEntry, error) {
+	query := `
+		SELECT p.id, p.created_at
+		FROM posts p
+		WHERE p.user_id = $1 AND p.deleted_at IS NULL AND p.status = 'published'
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT $2
+	`
+
+	return s.getEntries(ctx, query, authorID, limit)
+}
+
+func (s *TimelineStore) getEntries(ctx context.Context, query string, args ...any) ([]TimelineEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []TimelineEntry{}
+	for rows.Next() {
+		var e TimelineEntry
+		if err := rows.Scan(&e.PostId, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+// GetPulled returns a page of the feed of viewerID made only of the posts
+// that aren't fanned out: those of the popular authorIDs and the public
+// posts carrying tags the viewer follows.
+func (s *TimelineStore) GetPulled(ctx context.Context, viewerID int64, authorIDs []int64, fq PaginatedFeedQuery) ([]PostMetaData, error) {
+	return queryFeed(ctx, s.db, viewerID, fq, `
+		p.user_id = ANY($7::bigint[]) OR
+		(p.visibility = 'public' AND `+followsTag+`)
+	`, pq.Array(authorIDs))
+}
+
+// GetShownAbove returns which of contentIDs the feed of viewerID shows at or
+// above cursor, so pages walking down from the cursor can leave them out.
+func (s *TimelineStore) GetShownAbove(ctx context.Context, viewerID int64, cursor PostCursor, contentIDs []int64) ([]int64, error) {
+	query := feedCTE(contentID("p")+` = ANY($7::bigint[]) AND (`+feedCandidates+`)`, ">=") + `
+		SELECT f.content_id FROM feed f
+		LIMIT $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, viewerID, len(contentIDs), "", nil, cursor.CreatedAt, cursor.Id, pq.Array(contentIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
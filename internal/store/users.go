@@ -12,14 +12,27 @@ import (
 )
 
 type User struct {
-	Id        int64    `json:"id"`
-	Username  string   `json:"username"`
-	Email     string   `json:"email"`
-	Password  Password `json:"-"`
-	CreatedAt string   `json:"created_at"`
-	IsActive  bool     `json:"is_active"`
-	Role_Id   int64    `json:"role_id"`
-	Role      Role     `json:"role"`
+	Id          int64    `json:"id"`
+	Username    string   `json:"username"`
+	DisplayName string   `json:"display_name,omitempty"`
+	Email       string   `json:"email"`
+	Password    Password `json:"-"`
+	CreatedAt   string   `json:"created_at"`
+	IsActive    bool     `json:"is_active"`
+	Role_Id     int64    `json:"role_id"`
+	Role        Role     `json:"role"`
+	// TokensValidAfter revokes the tokens issued until then
+	TokensValidAfter *time.Time `json:"tokens_valid_after,omitempty"`
+}
+
+// UserListQuery filters the accounts listed to admins. Search matches the
+// start of the username, display name or email.
+type UserListQuery struct {
+	Search string `validate:"max=100"`
+	Role   string `validate:"max=50"`
+	Active *bool
+	Limit  int `validate:"gte=1,lte=100"`
+	Offset int `validate:"gte=0"`
 }
 
 type Password struct {
@@ -37,6 +50,11 @@ func (p *Password) Set(text string) error {
 	return nil
 }
 
+// Compare returns nil when text is the password.
+func (p *Password) Compare(text string) error {
+	return bcrypt.CompareHashAndPassword(p.hash, []byte(text))
+}
+
 type UserStore struct {
 	db *sql.DB
 }
@@ -44,11 +62,12 @@ type UserStore struct {
 var (
 	ErrDuplicateEmail    = errors.New("a user with that email already exists")
 	ErrDuplicateUsername = errors.New("a user with that username already exists")
+	ErrLastAdmin         = errors.New("the last active admin can't be demoted or deactivated")
 )
 
 func (s *UserStore) Create(ctx context.Context, user *User, tx *sql.Tx) error {
-	query := `INSERT INTO users (username, email,password,role_id) 
-	VALUES ($1,$2,$3,$4) RETURNING id,created_at
+	query := `INSERT INTO users (username, email,password,role_id,display_name) 
+	VALUES ($1,$2,$3,$4,NULLIF($5, '')) RETURNING id,created_at
 	`
 	err := tx.QueryRowContext(
 		ctx,
@@ -57,6 +76,7 @@ func (s *UserStore) Create(ctx context.Context, user *User, tx *sql.Tx) error {
 		user.Email,
 		user.Password.hash,
 		user.Role_Id,
+		user.DisplayName,
 	).Scan(
 		&user.Id,
 		&user.CreatedAt,
@@ -76,9 +96,10 @@ func (s *UserStore) Create(ctx context.Context, user *User, tx *sql.Tx) error {
 
 func (s *UserStore) GetUserByID(ctx context.Context, id int64) (*User, error) {
 	query := `
-		INSERT INTO users (username, password, email, role_id) VALUES 
-    ($1, $2, $3, (SELECT id FROM roles WHERE name = $4))
-    RETURNING id, created_at
+		SELECT u.id, u.username, COALESCE(u.display_name, ''), u.email, u.password, u.created_at, u.tokens_valid_after, r.id, r.name, r.level, r.description
+		FROM users u
+		JOIN roles r ON r.id = u.role_id
+		WHERE u.id = $1 AND u.is_active = true
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
@@ -92,9 +113,11 @@ func (s *UserStore) GetUserByID(ctx context.Context, id int64) (*User, error) {
 	).Scan(
 		&user.Id,
 		&user.Username,
+		&user.DisplayName,
 		&user.Email,
 		&user.Password.hash,
 		&user.CreatedAt,
+		&user.TokensValidAfter,
 		&user.Role.ID,
 		&user.Role.Name,
 		&user.Role.Level,
@@ -139,9 +162,10 @@ func (s *UserStore) createUserInvitation(ctx context.Context, tx *sql.Tx, token
 	return nil
 }
 
-func (s *UserStore) Activate(ctx context.Context, token string) error {
+func (s *UserStore) Activate(ctx context.Context, token string) (*User, error) {
+	var activated *User
 
-	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
 		user, err := s.getUserfromToken(ctx, token, tx)
 		if err != nil {
 			return err
@@ -154,9 +178,12 @@ func (s *UserStore) Activate(ctx context.Context, token string) error {
 			return err
 		}
 
+		activated = user
 		return nil
 
 	})
+
+	return activated, err
 }
 
 func (s *UserStore) update(ctx context.Context, tx *sql.Tx, user *User) error {
@@ -277,3 +304,249 @@ func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error)
 
 	return user, nil
 }
+
+// GetActive returns up to limit active users that aren't suspended with an
+// id greater than afterID, in id order, for batch jobs walking the whole
+// table.
+func (s *UserStore) GetActive(ctx context.Context, afterID int64, limit int) ([]User, error) {
+	query := `
+		SELECT id, username, COALESCE(display_name, ''), created_at
+		FROM users
+		WHERE is_active = true AND id > $1 AND ` + notSuspended("id") + `
+		ORDER BY id
+		LIMIT $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.Id, &u.Username, &u.DisplayName, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		u.IsActive = true
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
+
+const adminUserColumns = `
+	u.id, u.username, COALESCE(u.display_name, ''), u.email, u.created_at, u.is_active, u.tokens_valid_after,
+	r.id, r.name, r.level, r.description
+`
+
+func scanAdminUser(row interface{ Scan(...any) error }, u *User) error {
+	err := row.Scan(
+		&u.Id,
+		&u.Username,
+		&u.DisplayName,
+		&u.Email,
+		&u.CreatedAt,
+		&u.IsActive,
+		&u.TokensValidAfter,
+		&u.Role.ID,
+		&u.Role.Name,
+		&u.Role.Level,
+		&u.Role.Description,
+	)
+	u.Role_Id = u.Role.ID
+	return err
+}
+
+// List returns the accounts matching uq, active or not, newest first.
+func (s *UserStore) List(ctx context.Context, uq UserListQuery) ([]User, error) {
+	query := `
+		SELECT ` + adminUserColumns + `
+		FROM users u
+		JOIN roles r ON r.id = u.role_id
+		WHERE
+			($1 = '' OR u.username ILIKE $1 || '%' OR u.display_name ILIKE $1 || '%' OR u.email ILIKE $1 || '%') AND
+			($2 = '' OR r.name = $2) AND
+			($3::boolean IS NULL OR u.is_active = $3)
+		ORDER BY u.created_at DESC, u.id DESC
+		LIMIT $4 OFFSET $5
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, escapeLike(uq.Search), uq.Role, uq.Active, uq.Limit, uq.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		var u User
+		if err := scanAdminUser(rows, &u); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
+
+// GetAccount returns the user with id whether their account is active or
+// not, unlike GetUserByID.
+func (s *UserStore) GetAccount(ctx context.Context, id int64) (*User, error) {
+	query := `
+		SELECT ` + adminUserColumns + `
+		FROM users u
+		JOIN roles r ON r.id = u.role_id
+		WHERE u.id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	user := &User{}
+	err := scanAdminUser(s.db.QueryRowContext(ctx, query, id), user)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+// SetRole gives the user the role. ErrLastAdmin is returned when it would
+// leave no active admin.
+func (s *UserStore) SetRole(ctx context.Context, userID int64, role *Role) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if role.Name != "admin" {
+			if err := s.checkLastAdmin(ctx, tx, userID); err != nil {
+				return err
+			}
+		}
+
+		query := `UPDATE users SET role_id = $1 WHERE id = $2`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		res, err := tx.ExecContext(ctx, query, role.ID, userID)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return ErrNotFound
+		}
+
+		return nil
+	})
+}
+
+// SetActive activates or deactivates an account. Deactivating it revokes
+// its tokens and activating it drops its pending invitations. ErrLastAdmin
+// is returned when deactivating would leave no active admin.
+func (s *UserStore) SetActive(ctx context.Context, userID int64, active bool) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if !active {
+			if err := s.checkLastAdmin(ctx, tx, userID); err != nil {
+				return err
+			}
+		}
+
+		query := `
+			UPDATE users SET
+				is_active = $1,
+				tokens_valid_after = CASE WHEN $1 THEN tokens_valid_after ELSE NOW() END
+			WHERE id = $2
+		`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		res, err := tx.ExecContext(ctx, query, active, userID)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return ErrNotFound
+		}
+
+		if active {
+			return s.deleteUserInvitations(ctx, tx, userID)
+		}
+		return nil
+	})
+}
+
+// RevokeTokens signs the user out everywhere, the tokens issued until now
+// stop working.
+func (s *UserStore) RevokeTokens(ctx context.Context, userID int64) error {
+	query := `UPDATE users SET tokens_valid_after = NOW() WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// checkLastAdmin returns ErrLastAdmin when userID is the only active admin.
+// It locks the admins' rows, so concurrent demotions can't both pass.
+func (s *UserStore) checkLastAdmin(ctx context.Context, tx *sql.Tx, userID int64) error {
+	query := `
+		SELECT u.id
+		FROM users u
+		JOIN roles r ON r.id = u.role_id
+		WHERE r.name = 'admin' AND u.is_active = true
+		ORDER BY u.id
+		FOR UPDATE OF u
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var admins []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		admins = append(admins, id)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(admins) == 1 && admins[0] == userID {
+		return ErrLastAdmin
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package timeline
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/lunatictiol/go-based-social-media/internal/store"
+)
+
+// Feed returns a page of the home feed of viewerID the way
+// PostStore.GetUserFeed does, reading the fanned out posts from the
+// viewer's timeline and merging in the pulled ones. Like there, content is
+// only shown at its most recent appearance.
+func (f *Fanout) Feed(ctx context.Context, viewerID int64, fq store.PaginatedFeedQuery) ([]store.PostMetaData, error) {
+	// timelines are kept newest first and unfiltered
+	if fq.Sort != "desc" || fq.Search != "" || len(fq.Tags) > 0 {
+		return f.store.Posts.GetUserFeed(ctx, viewerID, fq)
+	}
+
+	length, built, err := f.cache.Len(ctx, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	if !built {
+		entries, err := f.store.Timelines.GetHome(ctx, viewerID, f.cfg.Length)
+		if err != nil {
+			return nil, err
+		}
+		if err := f.cache.Rebuild(ctx, viewerID, entries); err != nil {
+			return nil, err
+		}
+		length = len(entries)
+	}
+
+	newer := fq.Before != nil
+	cursor := fq.After
+	if newer {
+		cursor = fq.Before
+	}
+
+	pushed, exhausted, err := f.pushed(ctx, viewerID, cursor, newer, fq.Limit)
+	if err != nil {
+		return nil, err
+	}
+	// the posts past the end of a full timeline are only in Postgres
+	if exhausted && !newer && length >= f.cfg.Length {
+		return f.store.Posts.GetUserFeed(ctx, viewerID, fq)
+	}
+
+	popular, err := f.store.Timelines.GetPopularFollowed(ctx, viewerID, f.cfg.MaxFollowers)
+	if err != nil {
+		return nil, err
+	}
+	pulled, err := f.store.Timelines.GetPulled(ctx, viewerID, popular, fq)
+	if err != nil {
+		return nil, err
+	}
+	if newer {
+		// pulled pages come newest first, merge them walking from the cursor
+		slices.Reverse(pulled)
+	}
+
+	posts := append(pushed, pulled...)
+	slices.SortStableFunc(posts, func(a, b store.PostMetaData) int {
+		c := compare(a.Post, b.Post)
+		if newer {
+			return c
+		}
+		return -c
+	})
+
+	seen := map[int64]bool{}
+	seenContent := map[int64]bool{}
+	if cursor != nil && !newer && len(posts) > 0 {
+		// content that appeared at or above the cursor was already shown
+		contentIDs := make([]int64, len(posts))
+		for i, p := range posts {
+			contentIDs[i] = contentOf(p.Post)
+		}
+		shown, err := f.store.Timelines.GetShownAbove(ctx, viewerID, *cursor, contentIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range shown {
+			seenContent[id] = true
+		}
+	}
+
+	page := []store.PostMetaData{}
+	for _, p := range posts {
+		content := contentOf(p.Post)
+		if seen[p.Post.Id] || seenContent[content] {
+			continue
+		}
+		seen[p.Post.Id] = true
+		seenContent[content] = true
+
+		page = append(page, p)
+		if len(page) == fq.Limit {
+			break
+		}
+	}
+
+	if newer {
+		slices.Reverse(page)
+	}
+
+	return page, nil
+}
+
+// pushed reads at least limit posts the viewer can see from their timeline,
+// walking away from cursor. exhausted is set when the timeline ran out.
+func (f *Fanout) pushed(ctx context.Context, viewerID int64, cursor *store.PostCursor, newer bool, limit int) ([]store.PostMetaData, bool, error) {
+	posts := []store.PostMetaData{}
+
+	for len(posts) < limit {
+		entries, err := f.cache.Page(ctx, viewerID, cursor, newer, limit)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(entries) == 0 {
+			return posts, true, nil
+		}
+
+		ids := make([]int64, len(entries))
+		for i, e := range entries {
+			ids[i] = e.PostId
+		}
+		// posts deleted, unpublished or hidden since they were fanned out
+		// are left out here
+		rows, err := f.store.Posts.GetListByIDs(ctx, viewerID, ids)
+		if err != nil {
+			return nil, false, err
+		}
+		byID := make(map[int64]store.PostMetaData, len(rows))
+		for _, p := range rows {
+			byID[p.Post.Id] = p
+		}
+
+		for _, e := range entries {
+			p, ok := byID[e.PostId]
+			// pure reposts of posts the viewer can't see aren't shown
+			if !ok || (p.Post.Kind == store.PostKindRepost && p.Post.Original == nil) {
+				continue
+			}
+			posts = append(posts, p)
+		}
+
+		if len(entries) < limit {
+			return posts, true, nil
+		}
+		last := entries[len(entries)-1]
+		cursor = &store.PostCursor{CreatedAt: last.CreatedAt.Format(time.RFC3339Nano), Id: last.PostId}
+	}
+
+	return posts, false, nil
+}
+
+// contentOf returns the id of the post whose content post shows, its
+// original for pure reposts.
+func contentOf(post store.Post) int64 {
+	if post.Kind == store.PostKindRepost && post.RepostOf != nil {
+		return *post.RepostOf
+	}
+	return post.Id
+}
+
+// compare orders posts by creation time, then id.
+func compare(a, b store.Post) int {
+	ta, _ := time.Parse(time.RFC3339Nano, a.CreatedAt)
+	tb, _ := time.Parse(time.RFC3339Nano, b.CreatedAt)
+	if c := ta.Compare(tb); c != 0 {
+		return c
+	}
+	switch {
+	case a.Id < b.Id:
+		return -1
+	case a.Id > b.Id:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,172 @@
+// Package timeline keeps home timelines precomputed in Redis. Posts are
+// fanned out to their author's followers when they're published, except for
+// popular authors whose posts are pulled from Postgres when a timeline is
+// read, together with the posts of followed tags.
+package timeline
+
+import (
+	"context"
+	"time"
+
+	"github.com/lunatictiol/go-based-social-media/internal/store"
+	"go.uber.org/zap"
+)
+
+type EventType int
+
+const (
+	// PostPublished is sent when a post becomes visible in feeds: created
+	// published, published from a draft, published on schedule or restored
+	// from the trash.
+	PostPublished EventType = iota
+	// PostRemoved is sent when a post is deleted.
+	PostRemoved
+	Followed
+	Unfollowed
+)
+
+// Event tells the fan-out what changed. For post events ID is the post and
+// UserID its author, for follow events ID is the followed user and UserID
+// the follower.
+type Event struct {
+	Type   EventType
+	ID     int64
+	UserID int64
+}
+
+type Config struct {
+	// MaxFollowers is how many followers an author can have before their
+	// posts are pulled on read instead of fanned out
+	MaxFollowers int
+	// Length is how many posts a timeline keeps
+	Length int
+}
+
+// Cache holds the timelines.
+type Cache interface {
+	Len(ctx context.Context, userID int64) (int, bool, error)
+	Rebuild(ctx context.Context, userID int64, entries []store.TimelineEntry) error
+	Push(ctx context.Context, userIDs []int64, entries ...store.TimelineEntry) error
+	Remove(ctx context.Context, userIDs []int64, postIDs []int64) error
+	Page(ctx context.Context, userID int64, cursor *store.PostCursor, newer bool, count int) ([]store.TimelineEntry, error)
+}
+
+// Fanout updates timelines from a background goroutine so publishing a post
+// never waits on its followers. A nil *Fanout drops every event, feeds are
+// then read from Postgres.
+type Fanout struct {
+	store  store.Storage
+	cache  Cache
+	cfg    Config
+	logger *zap.SugaredLogger
+	events chan Event
+}
+
+func NewFanout(s store.Storage, cache Cache, cfg Config, logger *zap.SugaredLogger, buffer int) *Fanout {
+	return &Fanout{
+		store:  s,
+		cache:  cache,
+		cfg:    cfg,
+		logger: logger,
+		events: make(chan Event, buffer),
+	}
+}
+
+// Publish queues an event without blocking. Events are dropped when the
+// queue is full, the posts involved are still filtered when timelines are
+// read and timelines that expire are rebuilt from Postgres.
+func (f *Fanout) Publish(e Event) {
+	if f == nil {
+		return
+	}
+
+	select {
+	case f.events <- e:
+	default:
+		f.logger.Warnw("timeline event queue full, dropping event", "type", e.Type, "id", e.ID)
+	}
+}
+
+// Run handles events until ctx is cancelled.
+func (f *Fanout) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-f.events:
+			if err := f.handle(ctx, e); err != nil {
+				f.logger.Errorw("error updating timelines", "type", e.Type, "id", e.ID, "error", err)
+			}
+		}
+	}
+}
+
+func (f *Fanout) handle(ctx context.Context, e Event) error {
+	switch e.Type {
+	case PostPublished:
+		post, err := f.store.Posts.GetPostByID(ctx, e.ID)
+		if err == store.ErrNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if post.Status != store.PostStatusPublished {
+			return nil
+		}
+		createdAt, err := time.Parse(time.RFC3339Nano, post.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		targets, err := f.targets(ctx, post.UserId)
+		if err != nil {
+			return err
+		}
+		return f.cache.Push(ctx, targets, store.TimelineEntry{PostId: post.Id, CreatedAt: createdAt})
+
+	case PostRemoved:
+		targets, err := f.targets(ctx, e.UserID)
+		if err != nil {
+			return err
+		}
+		return f.cache.Remove(ctx, targets, []int64{e.ID})
+
+	case Followed:
+		popular, err := f.store.Timelines.IsPopular(ctx, e.ID, f.cfg.MaxFollowers)
+		if err != nil || popular {
+			return err
+		}
+		entries, err := f.store.Timelines.GetByAuthor(ctx, e.ID, f.cfg.Length)
+		if err != nil {
+			return err
+		}
+		return f.cache.Push(ctx, []int64{e.UserID}, entries...)
+
+	case Unfollowed:
+		entries, err := f.store.Timelines.GetByAuthor(ctx, e.ID, f.cfg.Length)
+		if err != nil {
+			return err
+		}
+		postIDs := make([]int64, len(entries))
+		for i, entry := range entries {
+			postIDs[i] = entry.PostId
+		}
+		return f.cache.Remove(ctx, []int64{e.UserID}, postIDs)
+	}
+
+	return nil
+}
+
+// targets returns the timelines the posts of authorID are fanned out to,
+// only their own when they are popular.
+func (f *Fanout) targets(ctx context.Context, authorID int64) ([]int64, error) {
+	followers, err := f.store.Timelines.GetFollowerIDs(ctx, authorID, f.cfg.MaxFollowers+1)
+	if err != nil {
+		return nil, err
+	}
+	if len(followers) > f.cfg.MaxFollowers {
+		return []int64{authorID}, nil
+	}
+	return append(followers, authorID), nil
+}
@@ -0,0 +1,76 @@
+package unfurl
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+func TestIsPublic(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"8.8.8.8", true},
+		{"93.184.216.34", true},
+		{"2606:4700:4700::1111", true},
+		{"127.0.0.1", false},
+		{"10.0.0.1", false},
+		{"172.16.5.4", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+		{"198.18.0.1", false},
+		{"192.0.2.10", false},
+		{"::1", false},
+		{"::", false},
+		{"fe80::1", false},
+		{"fc00::1", false},
+		{"2001:db8::1", false},
+		{"64:ff9b::7f00:1", false},
+		// IPv4-mapped addresses are checked as the IPv4 address they map
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:8.8.8.8", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := isPublic(netip.MustParseAddr(tt.addr)); got != tt.want {
+				t.Errorf("isPublic(%s) = %v, want %v", tt.addr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialControl(t *testing.T) {
+	tests := []struct {
+		address string
+		wantErr bool
+	}{
+		{"8.8.8.8:443", false},
+		{"[2606:4700:4700::1111]:80", false},
+		{"127.0.0.1:80", true},
+		{"[::1]:443", true},
+		{"10.1.2.3:8080", true},
+		{"[::ffff:169.254.169.254]:80", true},
+		// the dialer only ever passes resolved addresses, anything else is
+		// refused
+		{"localhost:80", true},
+		{"garbage", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			err := dialControl("tcp", tt.address, nil)
+			if tt.wantErr && !errors.Is(err, ErrForbiddenAddress) {
+				t.Errorf("dialControl(%s) = %v, want ErrForbiddenAddress", tt.address, err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("dialControl(%s) = %v, want nil", tt.address, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package unfurl
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// MaxLinks is how many links of a post get a preview.
+const MaxLinks = 4
+
+var linkPattern = regexp.MustCompile(`https?://[^\s<>"'` + "`" + `]+`)
+
+// ExtractURLs returns the distinct http and https urls found in content, in
+// order, at most MaxLinks of them. Punctuation trailing a url is taken as
+// part of the sentence around it.
+func ExtractURLs(content string) []string {
+	urls := []string{}
+	seen := map[string]bool{}
+
+	for _, match := range linkPattern.FindAllString(content, -1) {
+		match = trimTrailing(match)
+
+		u, err := url.Parse(match)
+		if err != nil || u.Host == "" {
+			continue
+		}
+		u.Fragment = ""
+		normalized := u.String()
+
+		if seen[normalized] {
+			continue
+		}
+		seen[normalized] = true
+		urls = append(urls, normalized)
+
+		if len(urls) == MaxLinks {
+			break
+		}
+	}
+
+	return urls
+}
+
+// trimTrailing drops sentence punctuation from the end of a url, keeping
+// closing parentheses that have an opening one inside the url.
+func trimTrailing(s string) string {
+	for len(s) > 0 {
+		last := s[len(s)-1]
+		switch {
+		case strings.IndexByte(".,:;!?*", last) >= 0:
+			s = s[:len(s)-1]
+		case last == ')' && strings.Count(s, "(") < strings.Count(s, ")"):
+			s = s[:len(s)-1]
+		case last == ']' && strings.Count(s, "[") < strings.Count(s, "]"):
+			s = s[:len(s)-1]
+		default:
+			return s
+		}
+	}
+	return s
+}
@@ -0,0 +1,43 @@
+package unfurl
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtractURLs(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"none", "no links here", []string{}},
+		{"one", "see https://example.com/a for more", []string{"https://example.com/a"}},
+		{"trailing punctuation", "read http://example.com/x. Or https://example.com/y!", []string{"http://example.com/x", "https://example.com/y"}},
+		{"parentheses", "(https://example.com/a) and https://en.wikipedia.org/wiki/Go_(language)", []string{"https://example.com/a", "https://en.wikipedia.org/wiki/Go_(language)"}},
+		{"fragments dropped and duplicates removed", "https://example.com/a#top https://example.com/a", []string{"https://example.com/a"}},
+		{"other schemes ignored", "ftp://example.com javascript:alert(1) mailto:a@example.com", []string{}},
+		{"quotes end a url", `"https://example.com/q"`, []string{"https://example.com/q"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtractURLs(tt.content); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExtractURLs(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractURLsLimit(t *testing.T) {
+	content := strings.Repeat("https://example.com/a ", 1) + "https://example.com/b https://example.com/c https://example.com/d https://example.com/e"
+
+	got := ExtractURLs(content)
+	if len(got) != MaxLinks {
+		t.Fatalf("got %d urls, want %d", len(got), MaxLinks)
+	}
+	if got[0] != "https://example.com/a" {
+		t.Errorf("first url = %s, want the first one in the content", got[0])
+	}
+}
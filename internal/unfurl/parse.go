@@ -0,0 +1,116 @@
+package unfurl
+
+import (
+	"io"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lunatictiol/go-based-social-media/internal/store"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	maxTitleLength       = 300
+	maxDescriptionLength = 1000
+)
+
+// parse reads the OpenGraph and Twitter card metadata from the head of an
+// HTML document. OpenGraph wins over Twitter cards, which win over the plain
+// <title> and description. base resolves relative image urls.
+func parse(r io.Reader, base *url.URL) *store.LinkPreview {
+	meta := map[string]string{}
+	var title string
+
+	z := html.NewTokenizer(r)
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+
+		token := z.Token()
+		if tt == html.EndTagToken && token.DataAtom == atom.Head {
+			break
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+
+		switch token.DataAtom {
+		case atom.Body:
+			// the metadata lives in the head, which may have been left open
+			return build(meta, title, base)
+		case atom.Title:
+			if title == "" && z.Next() == html.TextToken {
+				title = string(z.Text())
+			}
+		case atom.Meta:
+			var key, content string
+			for _, attr := range token.Attr {
+				switch attr.Key {
+				case "property", "name":
+					key = strings.ToLower(strings.TrimSpace(attr.Val))
+				case "content":
+					content = attr.Val
+				}
+			}
+			if _, ok := meta[key]; key != "" && !ok {
+				meta[key] = content
+			}
+		}
+	}
+
+	return build(meta, title, base)
+}
+
+func build(meta map[string]string, title string, base *url.URL) *store.LinkPreview {
+	first := func(keys ...string) string {
+		for _, key := range keys {
+			if v := clean(meta[key]); v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+
+	preview := &store.LinkPreview{
+		URL:         base.String(),
+		Title:       truncate(first("og:title", "twitter:title"), maxTitleLength),
+		Description: truncate(first("og:description", "twitter:description", "description"), maxDescriptionLength),
+		SiteName:    truncate(first("og:site_name"), maxTitleLength),
+		Type:        truncate(first("og:type"), maxTitleLength),
+		Image:       resolveImage(first("og:image:secure_url", "og:image", "twitter:image", "twitter:image:src"), base),
+	}
+	if preview.Title == "" {
+		preview.Title = truncate(clean(title), maxTitleLength)
+	}
+
+	return preview
+}
+
+// resolveImage resolves an image url against the page url. Anything that
+// doesn't end up as an http or https url is dropped.
+func resolveImage(image string, base *url.URL) string {
+	if image == "" {
+		return ""
+	}
+	u, err := base.Parse(image)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return u.String()
+}
+
+// clean collapses runs of whitespace and drops invalid UTF-8.
+func clean(s string) string {
+	return strings.Join(strings.Fields(strings.ToValidUTF8(s, "")), " ")
+}
+
+func truncate(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return string([]rune(s)[:max-1]) + "…"
+}
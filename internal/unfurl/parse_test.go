@@ -0,0 +1,130 @@
+package unfurl
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		page string
+		want map[string]string
+	}{
+		{
+			name: "opengraph wins over twitter cards and title",
+			page: `<html><head>
+				<title>Plain title</title>
+				<meta name="twitter:title" content="Twitter title">
+				<meta property="og:title" content="OG title">
+				<meta property="og:description" content="OG description">
+				<meta name="description" content="Plain description">
+				<meta property="og:site_name" content="Example">
+				<meta property="og:type" content="article">
+				<meta property="og:image" content="https://cdn.example.com/a.png">
+			</head><body></body></html>`,
+			want: map[string]string{
+				"title":       "OG title",
+				"description": "OG description",
+				"site_name":   "Example",
+				"type":        "article",
+				"image":       "https://cdn.example.com/a.png",
+			},
+		},
+		{
+			name: "twitter cards and plain tags as fallbacks",
+			page: `<head>
+				<title>Plain title</title>
+				<meta name="description" content="Plain description">
+				<meta name="twitter:image" content="/img/card.png">
+			</head>`,
+			want: map[string]string{
+				"title":       "Plain title",
+				"description": "Plain description",
+				"image":       "https://example.com/img/card.png",
+			},
+		},
+		{
+			name: "the first value of a key is kept",
+			page: `<head>
+				<meta property="og:title" content="First">
+				<meta property="og:title" content="Second">
+			</head>`,
+			want: map[string]string{"title": "First"},
+		},
+		{
+			name: "whitespace is collapsed",
+			page: "<head><title>\n  Spread \t over\n lines  </title></head>",
+			want: map[string]string{"title": "Spread over lines"},
+		},
+		{
+			name: "non http images are dropped",
+			page: `<head>
+				<meta property="og:title" content="x">
+				<meta property="og:image" content="javascript:alert(1)">
+			</head>`,
+			want: map[string]string{"title": "x", "image": ""},
+		},
+		{
+			name: "secure image url preferred",
+			page: `<head>
+				<meta property="og:image" content="http://example.com/a.png">
+				<meta property="og:image:secure_url" content="https://example.com/a.png">
+			</head>`,
+			want: map[string]string{"image": "https://example.com/a.png"},
+		},
+		{
+			name: "metadata in the body is ignored",
+			page: `<head><title>Head</title></head><body>
+				<meta property="og:title" content="Body">
+			</body>`,
+			want: map[string]string{"title": "Head"},
+		},
+		{
+			name: "an unclosed head ends at the body",
+			page: `<title>Head</title><meta property="og:site_name" content="Site"><body>
+				<meta property="og:title" content="Body">`,
+			want: map[string]string{"title": "Head", "site_name": "Site"},
+		},
+		{
+			name: "markup in values stays text",
+			page: `<head><meta property="og:title" content="&lt;script&gt;alert(1)&lt;/script&gt;"></head>`,
+			want: map[string]string{"title": "<script>alert(1)</script>"},
+		},
+	}
+
+	base, _ := url.Parse("https://example.com/posts/1")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := parse(strings.NewReader(tt.page), base)
+			got := map[string]string{
+				"title":       p.Title,
+				"description": p.Description,
+				"site_name":   p.SiteName,
+				"type":        p.Type,
+				"image":       p.Image,
+			}
+			for key, want := range tt.want {
+				if got[key] != want {
+					t.Errorf("%s = %q, want %q", key, got[key], want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseTruncates(t *testing.T) {
+	base, _ := url.Parse("https://example.com")
+	long := strings.Repeat("é", maxTitleLength+50)
+
+	p := parse(strings.NewReader(`<head><meta property="og:title" content="`+long+`"></head>`), base)
+
+	if n := len([]rune(p.Title)); n != maxTitleLength {
+		t.Fatalf("title has %d runes, want %d", n, maxTitleLength)
+	}
+	if !strings.HasSuffix(p.Title, "…") {
+		t.Errorf("truncated title %q doesn't end with an ellipsis", p.Title)
+	}
+}
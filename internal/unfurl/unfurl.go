@@ -0,0 +1,121 @@
+// Package unfurl builds link previews from the OpenGraph and Twitter card
+// metadata of web pages, without letting the links reach private networks.
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/lunatictiol/go-based-social-media/internal/store"
+	"golang.org/x/net/html/charset"
+)
+
+var (
+	ErrUnsupportedURL   = errors.New("unfurl: only http and https urls can be unfurled")
+	ErrTooManyRedirects = errors.New("unfurl: too many redirects")
+	ErrNotHTML          = errors.New("unfurl: not an html page")
+)
+
+type Config struct {
+	// Timeout bounds a whole fetch, redirects included
+	Timeout time.Duration
+	// MaxBytes is how much of a page is read, the metadata is expected in
+	// its head
+	MaxBytes     int64
+	MaxRedirects int
+	UserAgent    string
+	// AllowPrivateNetworks turns the SSRF guard off so a local fixture
+	// server can be unfurled. Never set it in production.
+	AllowPrivateNetworks bool
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Timeout:      time.Second * 5,
+		MaxBytes:     512 * 1024,
+		MaxRedirects: 3,
+		UserAgent:    "go-based-social-media-unfurler/1.0",
+	}
+}
+
+type Unfurler struct {
+	cfg    Config
+	client *http.Client
+}
+
+func New(cfg Config) *Unfurler {
+	transport := &http.Transport{
+		// a proxy would make the connections the dial guard checks
+		Proxy:                 nil,
+		DialContext:           newDialer(cfg).DialContext,
+		TLSHandshakeTimeout:   cfg.Timeout,
+		ResponseHeaderTimeout: cfg.Timeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       time.Minute,
+	}
+
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   cfg.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > cfg.MaxRedirects {
+				return ErrTooManyRedirects
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return ErrUnsupportedURL
+			}
+			return nil
+		},
+	}
+
+	return &Unfurler{cfg: cfg, client: client}
+}
+
+// Fetch downloads the page at rawURL and builds its preview. The preview
+// keeps rawURL as its url even when the page was reached through redirects,
+// relative image urls are resolved against the final one.
+func (u *Unfurler) Fetch(ctx context.Context, rawURL string) (*store.LinkPreview, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, ErrUnsupportedURL
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", u.cfg.UserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	res, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unfurl: unexpected status %d", res.StatusCode)
+	}
+
+	contentType := res.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || (mediaType != "text/html" && mediaType != "application/xhtml+xml") {
+		return nil, ErrNotHTML
+	}
+
+	body, err := charset.NewReader(io.LimitReader(res.Body, u.cfg.MaxBytes), contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := parse(body, res.Request.URL)
+	preview.URL = rawURL
+
+	return preview, nil
+}
@@ -0,0 +1,136 @@
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// newFixture serves the pages the fetch tests unfurl. /hop/N redirects N
+// more times before landing on /page.
+func newFixture(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, `<html><head>
+			<meta property="og:title" content="Fixture">
+			<meta property="og:image" content="/cover.png">
+		</head><body></body></html>`)
+	})
+	mux.HandleFunc("/hop/", func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/hop/"))
+		if n <= 1 {
+			http.Redirect(w, r, "/page", http.StatusFound)
+			return
+		}
+		http.Redirect(w, r, "/hop/"+strconv.Itoa(n-1), http.StatusFound)
+	})
+	mux.HandleFunc("/ftp", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "ftp://example.com/file", http.StatusFound)
+	})
+	mux.HandleFunc("/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"title":"not html"}`)
+	})
+	mux.HandleFunc("/big", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<html><head><!--"+strings.Repeat("x", 4096)+`-->
+			<meta property="og:title" content="Too far down">
+		</head></html>`)
+	})
+	mux.HandleFunc("/missing", http.NotFound)
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestFetch(t *testing.T) {
+	srv := newFixture(t)
+
+	cfg := DefaultConfig()
+	cfg.MaxBytes = 1024
+	cfg.AllowPrivateNetworks = true
+	u := New(cfg)
+
+	tests := []struct {
+		name      string
+		path      string
+		wantErr   bool
+		errIs     error
+		wantTitle string
+	}{
+		{name: "page", path: "/page", wantTitle: "Fixture"},
+		{name: "redirects within the limit", path: "/hop/3", wantTitle: "Fixture"},
+		{name: "too many redirects", path: "/hop/4", wantErr: true, errIs: ErrTooManyRedirects},
+		{name: "redirect to another scheme", path: "/ftp", wantErr: true, errIs: ErrUnsupportedURL},
+		{name: "not html", path: "/json", wantErr: true, errIs: ErrNotHTML},
+		{name: "metadata past the byte limit", path: "/big", wantTitle: ""},
+		{name: "not found", path: "/missing", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rawURL := srv.URL + tt.path
+			preview, err := u.Fetch(context.Background(), rawURL)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Fetch() = %+v, want an error", preview)
+				}
+				if tt.errIs != nil && !errors.Is(err, tt.errIs) {
+					t.Fatalf("Fetch() error = %v, want %v", err, tt.errIs)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if preview.URL != rawURL {
+				t.Errorf("URL = %s, want %s", preview.URL, rawURL)
+			}
+			if preview.Title != tt.wantTitle {
+				t.Errorf("Title = %q, want %q", preview.Title, tt.wantTitle)
+			}
+		})
+	}
+}
+
+func TestFetchResolvesImageAgainstFinalURL(t *testing.T) {
+	srv := newFixture(t)
+
+	cfg := DefaultConfig()
+	cfg.AllowPrivateNetworks = true
+
+	preview, err := New(cfg).Fetch(context.Background(), srv.URL+"/hop/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := srv.URL + "/cover.png"; preview.Image != want {
+		t.Errorf("Image = %s, want %s", preview.Image, want)
+	}
+}
+
+func TestFetchRejectsUnsupportedURLs(t *testing.T) {
+	u := New(DefaultConfig())
+
+	for _, rawURL := range []string{"ftp://example.com/", "javascript:alert(1)", "/relative", "https://", "::"} {
+		if _, err := u.Fetch(context.Background(), rawURL); !errors.Is(err, ErrUnsupportedURL) {
+			t.Errorf("Fetch(%q) error = %v, want ErrUnsupportedURL", rawURL, err)
+		}
+	}
+}
+
+func TestFetchGuardsPrivateNetworks(t *testing.T) {
+	srv := newFixture(t)
+
+	// the fixture listens on loopback, which the default guard refuses
+	_, err := New(DefaultConfig()).Fetch(context.Background(), srv.URL+"/page")
+	if !errors.Is(err, ErrForbiddenAddress) {
+		t.Fatalf("Fetch() error = %v, want ErrForbiddenAddress", err)
+	}
+}
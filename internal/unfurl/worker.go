@@ -0,0 +1,146 @@
+package unfurl
+
+import (
+	"context"
+	"time"
+
+	"github.com/lunatictiol/go-based-social-media/internal/store"
+	"go.uber.org/zap"
+)
+
+const (
+	// PreviewTTL is how long an unfurled preview is reused before the page
+	// is fetched again.
+	PreviewTTL = time.Hour * 24 * 7
+	// FailureTTL is how long a url that couldn't be unfurled is left alone.
+	FailureTTL = time.Hour
+)
+
+// Cache is a fast cache in front of the previews kept in Postgres.
+type Cache interface {
+	Get(ctx context.Context, url string) (*store.LinkPreview, error)
+	Set(ctx context.Context, preview *store.LinkPreview) error
+}
+
+// Worker unfurls the links of posts from a background goroutine so creating
+// a post never waits on the sites it links to. A nil *Worker drops every
+// post.
+type Worker struct {
+	unfurler *Unfurler
+	store    store.Storage
+	// cache is nil when Redis is disabled
+	cache  Cache
+	logger *zap.SugaredLogger
+	posts  chan int64
+}
+
+func NewWorker(unfurler *Unfurler, s store.Storage, cache Cache, logger *zap.SugaredLogger, buffer int) *Worker {
+	return &Worker{
+		unfurler: unfurler,
+		store:    s,
+		cache:    cache,
+		logger:   logger,
+		posts:    make(chan int64, buffer),
+	}
+}
+
+// Enqueue queues a post whose content changed without blocking. Posts are
+// dropped when the queue is full and keep their previous previews.
+func (wk *Worker) Enqueue(postID int64) {
+	if wk == nil {
+		return
+	}
+
+	select {
+	case wk.posts <- postID:
+	default:
+		wk.logger.Warnw("unfurl queue full, dropping post", "post", postID)
+	}
+}
+
+// Run unfurls queued posts until ctx is cancelled.
+func (wk *Worker) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case postID := <-wk.posts:
+			if err := wk.handle(ctx, postID); err != nil {
+				wk.logger.Errorw("error unfurling post links", "post", postID, "error", err)
+			}
+		}
+	}
+}
+
+func (wk *Worker) handle(ctx context.Context, postID int64) error {
+	post, err := wk.store.Posts.GetPostByID(ctx, postID)
+	if err == store.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	urls := ExtractURLs(post.Content)
+	if err := wk.store.LinkPreviews.SetPostLinks(ctx, post.Id, urls); err != nil {
+		return err
+	}
+
+	for _, url := range urls {
+		if err := wk.unfurl(ctx, url); err != nil {
+			wk.logger.Errorw("error unfurling link", "url", url, "error", err)
+		}
+	}
+
+	return nil
+}
+
+// unfurl makes sure a fresh preview of url is stored, checking the cache
+// and Postgres before fetching the page.
+func (wk *Worker) unfurl(ctx context.Context, url string) error {
+	if wk.cache != nil {
+		cached, err := wk.cache.Get(ctx, url)
+		if err != nil {
+			wk.logger.Warnw("error reading cached link preview", "url", url, "error", err)
+		}
+		if cached != nil {
+			return nil
+		}
+	}
+
+	stored, err := wk.store.LinkPreviews.Get(ctx, url)
+	if err != nil && err != store.ErrNotFound {
+		return err
+	}
+	if stored != nil && fresh(stored) {
+		return wk.cacheSet(ctx, stored)
+	}
+
+	preview, err := wk.unfurler.Fetch(ctx, url)
+	if err != nil {
+		wk.logger.Infow("link could not be unfurled", "url", url, "error", err)
+		preview = &store.LinkPreview{URL: url, Failed: true}
+	}
+
+	if err := wk.store.LinkPreviews.Save(ctx, preview); err != nil {
+		return err
+	}
+
+	return wk.cacheSet(ctx, preview)
+}
+
+// cacheSet caches successful previews, failures only live in Postgres.
+func (wk *Worker) cacheSet(ctx context.Context, preview *store.LinkPreview) error {
+	if wk.cache == nil || preview.Failed {
+		return nil
+	}
+	return wk.cache.Set(ctx, preview)
+}
+
+func fresh(preview *store.LinkPreview) bool {
+	ttl := PreviewTTL
+	if preview.Failed {
+		ttl = FailureTTL
+	}
+	return time.Since(preview.FetchedAt) < ttl
+}